@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+	"github.com/ockendenjo/handler"
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/ockendenjo/osm-pt-validator/pkg/replication"
+	"github.com/ockendenjo/osm-pt-validator/pkg/routes"
+	"github.com/ockendenjo/osm-pt-validator/pkg/util"
+)
+
+const indexKey = "replication/index.json"
+const nextIndexKey = "replication/index-next.json"
+const stateKey = "replication/state.json"
+
+// indexMaxAge is how long the index is used for before it is built again, to pick up changes to the routes files and
+// the nodes of ways which have been added to routes
+const indexMaxAge = 24 * time.Hour
+
+// indexBuildTime is how long each run spends loading relations into the index, which is built over several runs if
+// there are too many routes to load in one. It must leave enough of the Lambda timeout (stack/lambda-replication.tf)
+// for processing diffs and saving the index and state
+const indexBuildTime = 25 * time.Second
+
+func main() {
+	queueUrl := handler.MustGetEnv("QUEUE_URL")
+	bucketName := handler.MustGetEnv("S3_BUCKET_NAME")
+	userAgent := handler.MustGetEnv("USER_AGENT")
+	replicationUrl := handler.GetEnv("REPLICATION_URL")
+	if replicationUrl == "" {
+		replicationUrl = replication.MinutelyUrl
+	}
+	rateLimit := osm.RateLimit{
+		RequestsPerSecond: handler.MustGetEnvFloat("OSM_MAX_RPS"),
+		Burst:             handler.MustGetEnvInt("OSM_MAX_CONNS"),
+		MaxConcurrent:     handler.MustGetEnvInt("OSM_MAX_CONNS"),
+	}
+
+	handler.BuildAndStart(func(awsConfig aws.Config) handler.Handler[any, any] {
+		sqsClient := sqs.NewFromConfig(awsConfig)
+		s3Client := s3.NewFromConfig(awsConfig)
+		osmClient := osm.NewClient(userAgent).WithXRay().WithRateLimit(rateLimit).WithLogger(slog.Default())
+		if overpassUrl := handler.GetEnv("OVERPASS_URL"); overpassUrl != "" {
+			osmClient.WithOverpass(overpassUrl)
+		}
+
+		h := &lambdaHandler{
+			objects:           &objectStore{getObject: s3Client.GetObject, putObject: s3Client.PutObject, bucketName: bucketName},
+			listObjects:       s3Client,
+			provider:          osmClient,
+			replicationClient: replication.NewClient(replicationUrl, userAgent),
+			batchSend:         util.NewSQSBatchSender(sqsClient.SendMessageBatch, queueUrl),
+		}
+		return h.handle
+	})
+}
+
+type lambdaHandler struct {
+	objects           *objectStore
+	listObjects       s3.ListObjectsV2APIClient
+	provider          osm.Provider
+	replicationClient *replication.Client
+	batchSend         util.SQSBatchSender
+}
+
+func (h *lambdaHandler) handle(ctx *handler.Context, _ any) (any, error) {
+	logger := ctx.GetLogger()
+
+	var state replication.State
+	found, err := h.objects.get(ctx, stateKey, &state)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		//Start from the latest diff rather than processing the whole history
+		state, err = h.replicationClient.GetState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("starting replication", "sequenceNumber", state.SequenceNumber)
+		return nil, h.objects.put(ctx, stateKey, state)
+	}
+
+	index, building, err := h.getIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		//No diffs are skipped, they are processed once the first index has been built
+		logger.Info("waiting for the index to be built", "pending", len(building.Pending))
+		return nil, nil
+	}
+
+	checkEvents, last, err := replication.CheckDiffs(ctx, h.replicationClient, index, building, state.SequenceNumber)
+	if err != nil {
+		//Send the events for the diffs which were processed, so that they aren't lost
+		logger.Error("failed to process diff", "sequenceNumber", last+1, "error", err.Error())
+	}
+	logger.Info("processed diffs", "from", state.SequenceNumber+1, "to", last, "relations", len(checkEvents))
+
+	entries := []sqsTypes.SendMessageBatchRequestEntry{}
+	for _, event := range checkEvents {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, sqsTypes.SendMessageBatchRequestEntry{
+			Id:          aws.String(uuid.New().String()),
+			MessageBody: aws.String(string(body)),
+		})
+	}
+	err = h.batchSend(ctx, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.objects.put(ctx, indexKey, index)
+	if err != nil {
+		return nil, err
+	}
+	if building != nil {
+		err = h.objects.put(ctx, nextIndexKey, building)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, h.objects.put(ctx, stateKey, replication.State{SequenceNumber: last})
+}
+
+// getIndex loads the saved index. If it is missing or too old, part of the next index is built, and the saved index is
+// used until the next one has been built. The next index is returned too while it is being built, or nil
+func (h *lambdaHandler) getIndex(ctx *handler.Context) (*replication.Index, *replication.Index, error) {
+	var index replication.Index
+	found, err := h.objects.get(ctx, indexKey, &index)
+	if err != nil {
+		return nil, nil, err
+	}
+	if found && time.Since(index.BuiltAt) < indexMaxAge {
+		return &index, nil, nil
+	}
+
+	next, err := h.buildNextIndex(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if next.IsBuilt() {
+		ctx.GetLogger().Info("built index", "relations", len(next.Routes))
+		return next, nil, nil
+	}
+	if !found {
+		return nil, next, nil
+	}
+	return &index, next, nil
+}
+
+// buildNextIndex loads as many relations into the next index as there is time for, and saves it so that the next run
+// can carry on. A new build is started from the routes files if there isn't one in progress
+func (h *lambdaHandler) buildNextIndex(ctx *handler.Context) (*replication.Index, error) {
+	next := &replication.Index{}
+	found, err := h.objects.get(ctx, nextIndexKey, next)
+	if err != nil {
+		return nil, err
+	}
+	if !found || next.IsBuilt() {
+		files, err := h.loadRoutesFiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ctx.GetLogger().Info("building index", "files", len(files))
+		next = replication.NewIndexBuild(files)
+	}
+
+	buildErr := next.Build(ctx, h.provider, time.Now().Add(indexBuildTime))
+	ctx.GetLogger().Info("loaded relations into index", "relations", len(next.Routes), "pending", len(next.Pending))
+	if next.IsBuilt() && len(next.Failed) > 0 {
+		ctx.GetLogger().Warn("failed to load relations into index", "failed", next.Failed)
+	}
+
+	//Save the relations which were loaded even if the build was interrupted
+	err = h.objects.put(ctx, nextIndexKey, next)
+	if err != nil {
+		return nil, err
+	}
+	return next, buildErr
+}
+
+func (h *lambdaHandler) loadRoutesFiles(ctx context.Context) ([]routes.RoutesFile, error) {
+	files := []routes.RoutesFile{}
+	paginator := s3.NewListObjectsV2Paginator(h.listObjects, &s3.ListObjectsV2Input{
+		Bucket: &h.objects.bucketName,
+		Prefix: aws.String("routes"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, content := range page.Contents {
+			if !strings.HasSuffix(*content.Key, ".json") {
+				continue
+			}
+			var file routes.RoutesFile
+			_, err = h.objects.get(ctx, *content.Key, &file)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// objectStore saves and loads JSON objects in S3
+type objectStore struct {
+	getObject  util.GetObjectApi
+	putObject  util.PutObjectApi
+	bucketName string
+}
+
+func (s *objectStore) get(ctx context.Context, key string, v any) (bool, error) {
+	output, err := s.getObject(ctx, &s3.GetObjectInput{Bucket: &s.bucketName, Key: &key})
+	if _, notFound := errors.AsType[*s3Types.NoSuchKey](err); notFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (s *objectStore) put(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.putObject(ctx, &s3.PutObjectInput{
+		Bucket:      &s.bucketName,
+		Key:         &key,
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
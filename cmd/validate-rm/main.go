@@ -3,12 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
+	"log/slog"
+	"time"
 
 	sqsEvents "github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
@@ -17,6 +18,8 @@ import (
 	"github.com/ockendenjo/osm-pt-validator/pkg/events"
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 	"github.com/ockendenjo/osm-pt-validator/pkg/snsEvents"
+	"github.com/ockendenjo/osm-pt-validator/pkg/state"
+	"github.com/ockendenjo/osm-pt-validator/pkg/util"
 	"github.com/ockendenjo/osm-pt-validator/pkg/validation"
 )
 
@@ -24,19 +27,37 @@ func main() {
 	queueUrl := handler.MustGetEnv("QUEUE_URL")
 	topicArn := handler.MustGetEnv("TOPIC_ARN")
 	userAgent := handler.MustGetEnv("USER_AGENT")
+	rateLimit := osm.RateLimit{
+		RequestsPerSecond: handler.MustGetEnvFloat("OSM_MAX_RPS"),
+		Burst:             handler.MustGetEnvInt("OSM_MAX_CONNS"),
+		MaxConcurrent:     handler.MustGetEnvInt("OSM_MAX_CONNS"),
+	}
 
 	handler.BuildAndStart(func(awsConfig aws.Config) handler.Handler[sqsEvents.SQSEvent, sqsEvents.SQSEventResponse] {
 		sqsClient := sqs.NewFromConfig(awsConfig)
 		snsClient := sns.NewFromConfig(awsConfig)
-		osmClient := osm.NewClient(userAgent).WithXRay()
+		cache := osm.DefaultMemoryCache()
+		osmClient := osm.NewClient(userAgent).WithXRay().WithRateLimit(rateLimit).WithLogger(slog.Default()).WithCache(cache)
+		if overpassUrl := handler.GetEnv("OVERPASS_URL"); overpassUrl != "" {
+			osmClient.WithOverpass(overpassUrl)
+		}
 
 		h := &lambdaHandler{
 			sendMessageBatch: sqsClient.SendMessageBatch,
 			queueUrl:         queueUrl,
-			osmClient:        osmClient,
+			provider:         osmClient,
+			cache:            cache,
 			publish:          snsClient.Publish,
 			topicArn:         topicArn,
 		}
+		s3Client := s3.NewFromConfig(awsConfig)
+		if bucketName := handler.GetEnv("CASSETTE_BUCKET_NAME"); bucketName != "" {
+			osmClient.WithRecording()
+			h.uploadCassette = util.NewCassetteUploader(s3Client.PutObject, bucketName)
+		}
+		if bucketName := handler.GetEnv("STATE_BUCKET_NAME"); bucketName != "" {
+			h.stateStore = state.NewS3Store(s3Client.GetObject, s3Client.PutObject, bucketName)
+		}
 		return handler.GetSQSHandler(h, nil)
 	})
 }
@@ -44,19 +65,24 @@ func main() {
 type lambdaHandler struct {
 	sendMessageBatch sendMessageBatchApi
 	queueUrl         string
-	osmClient        *osm.OSMClient
+	provider         osm.Provider
+	cache            *osm.MemoryCache
 	publish          publishApi
 	topicArn         string
+	uploadCassette   util.CassetteUploader
+	stateStore       state.Store
 }
 
 func (h *lambdaHandler) ProcessSQSEvent(ctx *handler.Context, event events.CheckRelationEvent, _ map[string]sqsEvents.SQSMessageAttribute) error {
 
-	validator := validation.NewValidator(event.Config, h.osmClient)
+	validator := validation.NewValidator(event.Config, h.provider)
 
 	logger := ctx.GetLogger().AddParam("relationID", event.RelationID)
-	relation, err := h.osmClient.GetRelation(ctx, event.RelationID)
+	defer util.LogCacheStats(logger, h.cache)
+	cassette := osm.NewCassette()
+	relation, err := h.provider.GetRelation(osm.ContextWithCassette(ctx, cassette), event.RelationID)
 	if err != nil {
-		if hse, ok := errors.AsType[osm.HttpStatusError](err); ok && hse.StatusCode == http.StatusGone {
+		if osm.IsDeleted(err) {
 			goneErr := h.handleGone(ctx, event.RelationID)
 			return goneErr
 		}
@@ -66,7 +92,7 @@ func (h *lambdaHandler) ProcessSQSEvent(ctx *handler.Context, event events.Check
 	logger.Info("processing relation", "type", relation.Tags["type"])
 
 	if relation.Tags["type"] == "route_master" {
-		return h.handleRouteMaster(ctx, validator, relation)
+		return h.handleRouteMaster(ctx, validator, relation, cassette)
 	}
 	if relation.Tags["type"] == "route" {
 		return h.handleRoute(ctx, relation, event.Config)
@@ -115,19 +141,47 @@ func (h *lambdaHandler) handleRoute(ctx *handler.Context, element osm.Relation,
 	return err
 }
 
-func (h *lambdaHandler) handleRouteMaster(ctx *handler.Context, validator *validation.Validator, element osm.Relation) error {
+func (h *lambdaHandler) handleRouteMaster(ctx *handler.Context, validator *validation.Validator, element osm.Relation, cassette *osm.Cassette) error {
 	logger := ctx.GetLogger()
 	logger.Info("processing route_master relation")
 	messages := []sqsTypes.SendMessageBatchRequestEntry{}
 
-	validationErrors := validator.RouteMaster(element)
-	if len(validationErrors) > 0 {
-		logger.Error("relation is invalid", "validationErrors", validationErrors)
+	cassetteCtx := osm.ContextWithCassette(ctx, cassette)
+	variants, err := h.loadVariants(cassetteCtx, element)
+	if err != nil {
+		return err
+	}
+
+	//Route variants are queued and validated separately, so the fingerprint only covers the route master and the
+	//variants' own tags
+	full := osm.FullRelation{Relation: element, Relations: map[int64]osm.Relation{}}
+	for _, variant := range variants {
+		full.Relations[variant.ID] = variant
+	}
+	fingerprint, err := state.NewFingerprint(full, validator.GetConfig())
+	if err != nil {
+		return err
+	}
+	result, found := state.GetPreviousResult(ctx, h.stateStore, element.ID)
+	unchanged := found && result.Fingerprint.Equal(fingerprint)
+	if unchanged {
+		logger.Info("relation has not changed since last validation", "validatedAt", result.ValidatedAt)
+	} else {
+		validationErrors := validator.RouteMaster(element, variants)
+		result = state.Result{Fingerprint: fingerprint, ValidationErrors: validationErrors, ValidatedAt: time.Now().UTC()}
+		if len(result.ValidationErrors) > 0 {
+			result.Cassette = util.SaveCassette(ctx, h.uploadCassette, element.ID, cassette)
+		}
+	}
+
+	if len(result.ValidationErrors) > 0 {
+		logger.Error("relation is invalid", "validationErrors", result.ValidationErrors)
 
 		outputEvent := snsEvents.InvalidRelationEvent{
 			RelationID:       element.ID,
 			RelationName:     element.Tags["name"],
-			ValidationErrors: validationErrors,
+			ValidationErrors: result.ValidationErrors,
+			Cassette:         result.Cassette,
 		}
 		bytes, err := json.Marshal(outputEvent)
 		if err != nil {
@@ -143,6 +197,9 @@ func (h *lambdaHandler) handleRouteMaster(ctx *handler.Context, validator *valid
 			return err
 		}
 	}
+	if !unchanged {
+		state.SaveResult(ctx, h.stateStore, element.ID, result)
+	}
 
 	for _, member := range element.Members {
 		if member.Type == "relation" {
@@ -167,5 +224,25 @@ func (h *lambdaHandler) handleRouteMaster(ctx *handler.Context, validator *valid
 	return nil
 }
 
+// loadVariants loads the route relations of a route master. Variants which have been deleted are left out, as they are
+// reported when they are checked themselves
+func (h *lambdaHandler) loadVariants(ctx context.Context, element osm.Relation) ([]osm.Relation, error) {
+	variants := []osm.Relation{}
+	for _, member := range element.Members {
+		if member.Type != "relation" {
+			continue
+		}
+		variant, err := h.provider.GetRelation(ctx, member.Ref)
+		if osm.IsDeleted(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
 type sendMessageBatchApi func(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
 type publishApi func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
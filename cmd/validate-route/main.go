@@ -4,30 +4,61 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/ockendenjo/osm-pt-validator/pkg/events"
 	"github.com/ockendenjo/osm-pt-validator/pkg/snsEvents"
+	"github.com/ockendenjo/osm-pt-validator/pkg/state"
+	"github.com/ockendenjo/osm-pt-validator/pkg/util"
 	"github.com/ockendenjo/osm-pt-validator/pkg/validation"
 
 	sqsEvents "github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/ockendenjo/handler"
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 )
 
+// blameTimeout limits the time spent finding the changesets which broke a relation, so that the relation is still
+// reported within the lambda timeout
+const blameTimeout = 8 * time.Second
+
 func main() {
 	topicArn := handler.MustGetEnv("TOPIC_ARN")
 	userAgent := handler.MustGetEnv("USER_AGENT")
+	rateLimit := osm.RateLimit{
+		RequestsPerSecond: handler.MustGetEnvFloat("OSM_MAX_RPS"),
+		Burst:             handler.MustGetEnvInt("OSM_MAX_CONNS"),
+		MaxConcurrent:     handler.MustGetEnvInt("OSM_MAX_CONNS"),
+	}
 
 	handler.BuildAndStart(func(awsConfig aws.Config) handler.Handler[sqsEvents.SQSEvent, sqsEvents.SQSEventResponse] {
 		snsClient := sns.NewFromConfig(awsConfig)
-		osmClient := osm.NewClient(userAgent).WithXRay()
+		cache := osm.DefaultMemoryCache()
+		osmClient := osm.NewClient(userAgent).WithXRay().WithRateLimit(rateLimit).WithLogger(slog.Default()).WithCache(cache)
+		//Element history is only available from the OSM API, so blame uses a separate client when Overpass is used
+		historyClient := osmClient
+		if overpassUrl := handler.GetEnv("OVERPASS_URL"); overpassUrl != "" {
+			osmClient.WithOverpass(overpassUrl)
+			historyClient = osm.NewClient(userAgent).WithXRay().WithRateLimit(rateLimit).WithLogger(slog.Default())
+		}
 
 		h := &lambdaHandler{
-			osmClient: osmClient,
-			publish:   snsClient.Publish,
-			topicArn:  topicArn,
+			provider:      osmClient,
+			historyClient: historyClient,
+			cache:         cache,
+			publish:       snsClient.Publish,
+			topicArn:      topicArn,
+		}
+		s3Client := s3.NewFromConfig(awsConfig)
+		if bucketName := handler.GetEnv("CASSETTE_BUCKET_NAME"); bucketName != "" {
+			osmClient.WithRecording()
+			h.uploadCassette = util.NewCassetteUploader(s3Client.PutObject, bucketName)
+		}
+		if bucketName := handler.GetEnv("STATE_BUCKET_NAME"); bucketName != "" {
+			h.stateStore = state.NewS3Store(s3Client.GetObject, s3Client.PutObject, bucketName)
 		}
 
 		return handler.GetSQSHandler(h, func(lp *handler.LoggerParams, event events.CheckRelationEvent) {
@@ -37,51 +68,103 @@ func main() {
 }
 
 type lambdaHandler struct {
-	osmClient *osm.OSMClient
-	publish   publishApi
-	topicArn  string
+	provider       osm.Provider
+	historyClient  *osm.OSMClient
+	stateStore     state.Store
+	cache          *osm.MemoryCache
+	publish        publishApi
+	topicArn       string
+	uploadCassette util.CassetteUploader
 }
 
 func (h *lambdaHandler) ProcessSQSEvent(ctx *handler.Context, event events.CheckRelationEvent, _ map[string]sqsEvents.SQSMessageAttribute) error {
 	logger := ctx.GetLogger()
 	logger.Info("validating relation")
+	defer util.LogCacheStats(logger, h.cache)
+
+	cassette := osm.NewCassette()
+	osmCtx := osm.ContextWithCassette(ctx, cassette)
+	full, err := h.provider.GetRelationFull(osmCtx, event.RelationID)
+	if err != nil {
+		return err
+	}
+	relation := full.Relation
 
-	relation, err := h.osmClient.GetRelation(ctx, event.RelationID)
+	//The validator keeps the stop areas it loads for the fingerprint, so validating the relation doesn't load them again.
+	//They have to be loaded before an unchanged relation can be skipped, as the results depend on them
+	validator := validation.NewValidator(event.Config, h.provider)
+	err = validator.AddStopAreas(osmCtx, &full)
 	if err != nil {
 		return err
 	}
+	fingerprint, err := state.NewFingerprint(full, event.Config)
+	if err != nil {
+		return err
+	}
+	if previous, found := state.GetPreviousResult(ctx, h.stateStore, event.RelationID); found && previous.Fingerprint.Equal(fingerprint) {
+		logger.Info("relation has not changed since last validation", "validatedAt", previous.ValidatedAt)
+		return h.report(ctx, relation, previous)
+	}
 
-	validator := validation.NewValidator(event.Config, h.osmClient)
-	validationErrors, err := validator.RouteRelation(ctx, relation)
+	validationErrors, err := validator.RouteRelation(osmCtx, relation)
 	if err != nil {
 		return err
 	}
 
+	result := state.Result{Fingerprint: fingerprint, ValidationErrors: validationErrors, ValidatedAt: time.Now().UTC()}
 	if len(validationErrors) > 0 {
-		logger.Error("relation is invalid", "validationErrors", validationErrors)
+		result.Culprits = h.blame(osmCtx, logger, validator, relation, validationErrors)
+		result.Cassette = util.SaveCassette(ctx, h.uploadCassette, event.RelationID, cassette)
+	}
+	err = h.report(ctx, relation, result)
+	if err != nil {
+		return err
+	}
+	state.SaveResult(ctx, h.stateStore, event.RelationID, result)
+	return nil
+}
 
-		outputEvent := snsEvents.InvalidRelationEvent{
-			RelationID:       event.RelationID,
-			RelationURL:      fmt.Sprintf("https://openstreetmap.org/relation/%d", event.RelationID),
-			RelationName:     relation.Tags["name"],
-			ValidationErrors: validationErrors,
-		}
-		bytes, err := json.MarshalIndent(outputEvent, "", "    ")
-		if err != nil {
-			return err
-		}
+// report publishes an event if the relation is invalid
+func (h *lambdaHandler) report(ctx *handler.Context, relation osm.Relation, result state.Result) error {
+	logger := ctx.GetLogger()
+	if len(result.ValidationErrors) < 1 {
+		logger.Info("relation is valid")
+		return nil
+	}
+	logger.Error("relation is invalid", "validationErrors", result.ValidationErrors)
 
-		_, err = h.publish(ctx, &sns.PublishInput{
-			Message:  aws.String(string(bytes)),
-			Subject:  aws.String(fmt.Sprintf("Invalid relation %d", event.RelationID)),
-			TopicArn: &h.topicArn,
-		})
-		if err != nil {
-			return err
-		}
+	outputEvent := snsEvents.InvalidRelationEvent{
+		RelationID:       relation.ID,
+		RelationURL:      fmt.Sprintf("https://openstreetmap.org/relation/%d", relation.ID),
+		RelationName:     relation.Tags["name"],
+		ValidationErrors: result.ValidationErrors,
+		Culprits:         result.Culprits,
+		Cassette:         result.Cassette,
 	}
-	logger.Info("relation is valid")
-	return nil
+	bytes, err := json.MarshalIndent(outputEvent, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = h.publish(ctx, &sns.PublishInput{
+		Message:  aws.String(string(bytes)),
+		Subject:  aws.String(fmt.Sprintf("Invalid relation %d", relation.ID)),
+		TopicArn: &h.topicArn,
+	})
+	return err
+}
+
+// blame finds the changesets which broke the relation. The relation should still be reported if this fails, so errors
+// are only logged
+func (h *lambdaHandler) blame(ctx context.Context, logger *handler.Logger, validator *validation.Validator, relation osm.Relation, validationErrors []validation.ValidationError) []validation.Culprit {
+	ctx, cancel := context.WithTimeout(ctx, blameTimeout)
+	defer cancel()
+
+	culprits, err := validator.Blame(ctx, h.historyClient.History(), relation, validationErrors)
+	if err != nil {
+		logger.Error("failed to find changesets which broke relation", "error", err.Error())
+	}
+	return culprits
 }
 
 type publishApi func(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
@@ -0,0 +1,122 @@
+package osm
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultMemoryCacheSize = 50000
+const defaultMemoryCacheMaxAge = time.Hour
+
+// Cache stores the nodes and ways loaded by an OSMClient, so they don't need to be loaded again
+type Cache interface {
+	GetNode(nodeId int64) (Node, bool)
+	PutNode(node Node)
+	GetWay(wayId int64) (Way, bool)
+	PutWay(way Way)
+	// GetRelationVersion returns the version of a relation when it was last loaded
+	GetRelationVersion(relationId int64) (int32, bool)
+	PutRelationVersion(relationId int64, version int32)
+	// Invalidate removes an element from the cache. elemType is "node" or "way"
+	Invalidate(elemType string, id int64)
+}
+
+// MemoryCache is a Cache which holds a bounded number of nodes and ways in memory. The least recently used elements
+// are evicted when it is full, and elements expire once they are older than the maximum age
+type MemoryCache struct {
+	mu        sync.Mutex
+	nodes     *lru[Node]
+	ways      *lru[Way]
+	relations *lru[int32]
+	stats     CacheStats
+}
+
+// CacheStats counts the lookups made against a MemoryCache
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Entries   int
+}
+
+func DefaultMemoryCache() *MemoryCache {
+	return NewMemoryCache(defaultMemoryCacheSize, defaultMemoryCacheMaxAge)
+}
+
+// NewMemoryCache creates a cache holding up to maxEntries nodes and maxEntries ways. Zero values disable the size
+// limit or maximum age
+func NewMemoryCache(maxEntries int, maxAge time.Duration) *MemoryCache {
+	return &MemoryCache{
+		nodes:     newLRU[Node](maxEntries, maxAge, time.Now),
+		ways:      newLRU[Way](maxEntries, maxAge, time.Now),
+		relations: newLRU[int32](maxEntries, 0, time.Now),
+	}
+}
+
+func (m *MemoryCache) GetNode(nodeId int64) (Node, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, found := m.nodes.get(nodeId)
+	m.count(found)
+	return node, found
+}
+
+func (m *MemoryCache) PutNode(node Node) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Evictions += int64(m.nodes.put(node.ID, node))
+}
+
+func (m *MemoryCache) GetWay(wayId int64) (Way, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	way, found := m.ways.get(wayId)
+	m.count(found)
+	return way, found
+}
+
+func (m *MemoryCache) PutWay(way Way) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Evictions += int64(m.ways.put(way.ID, way))
+}
+
+func (m *MemoryCache) GetRelationVersion(relationId int64) (int32, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.relations.get(relationId)
+}
+
+func (m *MemoryCache) PutRelationVersion(relationId int64, version int32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.relations.put(relationId, version)
+}
+
+func (m *MemoryCache) Invalidate(elemType string, id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch elemType {
+	case "node":
+		m.nodes.remove(id)
+	case "way":
+		m.ways.remove(id)
+	}
+}
+
+// Stats returns the hit, miss and eviction counts since the cache was created
+func (m *MemoryCache) Stats() CacheStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	stats.Entries = m.nodes.len() + m.ways.len()
+	return stats
+}
+
+func (m *MemoryCache) count(hit bool) {
+	if hit {
+		m.stats.Hits++
+	} else {
+		m.stats.Misses++
+	}
+}
@@ -0,0 +1,92 @@
+package osm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_memoryCache(t *testing.T) {
+	testcases := []struct {
+		name    string
+		testFn  func(t *testing.T, cache *MemoryCache)
+		checkFn func(t *testing.T, cache *MemoryCache)
+	}{
+		{
+			name: "should count hits and misses",
+			testFn: func(t *testing.T, cache *MemoryCache) {
+				cache.PutWay(Way{ID: 1})
+				cache.GetWay(1)
+				cache.GetWay(2)
+				cache.GetNode(1)
+			},
+			checkFn: func(t *testing.T, cache *MemoryCache) {
+				assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 1}, cache.Stats())
+			},
+		},
+		{
+			name: "should evict least recently used element",
+			testFn: func(t *testing.T, cache *MemoryCache) {
+				cache.PutWay(Way{ID: 1})
+				cache.PutWay(Way{ID: 2})
+				cache.GetWay(1)
+				cache.PutWay(Way{ID: 3})
+			},
+			checkFn: func(t *testing.T, cache *MemoryCache) {
+				_, found := cache.GetWay(1)
+				assert.True(t, found)
+				_, found = cache.GetWay(2)
+				assert.False(t, found)
+				_, found = cache.GetWay(3)
+				assert.True(t, found)
+				assert.Equal(t, int64(1), cache.Stats().Evictions)
+			},
+		},
+		{
+			name: "should expire elements older than max age",
+			testFn: func(t *testing.T, cache *MemoryCache) {
+				cache.PutNode(Node{ID: 1})
+				cache.nodes.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+			},
+			checkFn: func(t *testing.T, cache *MemoryCache) {
+				_, found := cache.GetNode(1)
+				assert.False(t, found)
+				assert.Equal(t, 0, cache.Stats().Entries)
+			},
+		},
+		{
+			name: "should replace existing element",
+			testFn: func(t *testing.T, cache *MemoryCache) {
+				cache.PutNode(Node{ID: 1, Version: 1})
+				cache.PutNode(Node{ID: 1, Version: 2})
+			},
+			checkFn: func(t *testing.T, cache *MemoryCache) {
+				node, found := cache.GetNode(1)
+				require.True(t, found)
+				assert.Equal(t, int32(2), node.Version)
+				assert.Equal(t, 1, cache.Stats().Entries)
+			},
+		},
+		{
+			name: "should invalidate element",
+			testFn: func(t *testing.T, cache *MemoryCache) {
+				cache.PutWay(Way{ID: 1})
+				cache.Invalidate("way", 1)
+			},
+			checkFn: func(t *testing.T, cache *MemoryCache) {
+				_, found := cache.GetWay(1)
+				assert.False(t, found)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := NewMemoryCache(2, time.Hour)
+			tc.testFn(t, cache)
+			tc.checkFn(t, cache)
+		})
+	}
+}
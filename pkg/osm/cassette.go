@@ -0,0 +1,267 @@
+package osm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// recordedHeaders are the response headers saved in a cassette
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// cachedMethod is the method of the interactions which hold elements read from the client cache. They aren't requests,
+// so they are never matched by the replay transport
+const cachedMethod = "CACHE"
+
+// Cassette holds the responses recorded during a validation run, so the run can be replayed later against exactly the
+// same data
+type Cassette struct {
+	mu           sync.Mutex
+	Interactions []Interaction `json:"interactions"`
+	replayed     map[string]int
+	cached       map[string]int
+}
+
+// Interaction is a single recorded request and its response. Nodes and ways which were read from the client cache
+// instead of being requested are recorded as interactions with the method CACHE and a URL such as way/123, so that
+// they can be replayed by a client whose cache is empty
+type Interaction struct {
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	RequestBody  string            `json:"requestBody,omitempty"`
+	StatusCode   int               `json:"statusCode"`
+	Header       map[string]string `json:"header,omitempty"`
+	ResponseBody string            `json:"responseBody"`
+}
+
+func NewCassette() *Cassette {
+	return &Cassette{Interactions: []Interaction{}}
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- File inclusion via variable is intentional
+	if err != nil {
+		return nil, err
+	}
+	cassette := NewCassette()
+	err = json.Unmarshal(data, cassette)
+	if err != nil {
+		return nil, err
+	}
+	return cassette, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (c *Cassette) Marshal() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return json.MarshalIndent(c, "", "    ")
+}
+
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Interactions)
+}
+
+func (c *Cassette) record(interaction Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+// recordCached records a node or way read from the client cache, unless it has already been recorded
+func (c *Cassette) recordCached(elemType string, id int64, element any) error {
+	key := getCacheKey(elemType, id)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexCached()
+	if _, found := c.cached[key]; found {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string][]any{"elements": {element}})
+	if err != nil {
+		return err
+	}
+	c.cached[key] = len(c.Interactions)
+	c.Interactions = append(c.Interactions, Interaction{
+		Method:       cachedMethod,
+		URL:          key,
+		StatusCode:   http.StatusOK,
+		Header:       map[string]string{"Content-Type": "application/json"},
+		ResponseBody: string(body),
+	})
+	return nil
+}
+
+// getCached returns the elements recorded by recordCached for a node or way
+func (c *Cassette) getCached(elemType string, id int64) (*response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexCached()
+	idx, found := c.cached[getCacheKey(elemType, id)]
+	if !found {
+		return nil, false
+	}
+	interaction := c.Interactions[idx]
+	res, err := decodeResponse(interaction.Header["Content-Type"], []byte(interaction.ResponseBody))
+	if err != nil {
+		return nil, false
+	}
+	return res, true
+}
+
+// indexCached finds the cached elements in the interactions, which have been loaded from a file if the index is empty
+func (c *Cassette) indexCached() {
+	if c.cached != nil {
+		return
+	}
+	c.cached = map[string]int{}
+	for i, interaction := range c.Interactions {
+		if interaction.Method == cachedMethod {
+			c.cached[interaction.URL] = i
+		}
+	}
+}
+
+func getCacheKey(elemType string, id int64) string {
+	return fmt.Sprintf("%s/%d", elemType, id)
+}
+
+// replay returns the next recorded response for a request. Identical requests are answered in the order they were
+// recorded, and the last response is repeated once they have all been replayed
+func (c *Cassette) replay(method, url, body string) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := method + " " + url + "\n" + body
+	if c.replayed == nil {
+		c.replayed = map[string]int{}
+	}
+	matches := []Interaction{}
+	for _, interaction := range c.Interactions {
+		if interaction.Method == method && interaction.URL == url && interaction.RequestBody == body {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) < 1 {
+		return Interaction{}, false
+	}
+	idx := min(c.replayed[key], len(matches)-1)
+	c.replayed[key]++
+	return matches[idx], true
+}
+
+type cassetteKey struct{}
+
+// ContextWithCassette attaches a cassette to a context. A client with recording enabled saves the responses to any
+// requests made with the context in the cassette
+func ContextWithCassette(ctx context.Context, cassette *Cassette) context.Context {
+	return context.WithValue(ctx, cassetteKey{}, cassette)
+}
+
+func cassetteFromContext(ctx context.Context) *Cassette {
+	cassette, _ := ctx.Value(cassetteKey{}).(*Cassette)
+	return cassette
+}
+
+// recordingTransport saves each response in the cassette attached to the request context
+type recordingTransport struct {
+	next http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cassette := cassetteFromContext(req.Context())
+	if cassette == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	header := map[string]string{}
+	for _, name := range recordedHeaders {
+		if value := res.Header.Get(name); value != "" {
+			header[name] = value
+		}
+	}
+	cassette.record(Interaction{
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		RequestBody:  requestBody,
+		StatusCode:   res.StatusCode,
+		Header:       header,
+		ResponseBody: string(responseBody),
+	})
+	return res, nil
+}
+
+// replayTransport answers requests from a cassette instead of making them
+type replayTransport struct {
+	cassette *Cassette
+}
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	interaction, found := t.cassette.replay(req.Method, req.URL.String(), requestBody)
+	if !found {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+	}
+
+	header := http.Header{}
+	for name, value := range interaction.Header {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(interaction.ResponseBody))),
+		ContentLength: int64(len(interaction.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+func readRequestBody(req *http.Request) (string, error) {
+	if req.GetBody == nil {
+		return "", nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
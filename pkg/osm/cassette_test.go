@@ -0,0 +1,114 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_recordAndReplay(t *testing.T) {
+	fullBytes, err := os.ReadFile("testdata/relation_full.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			//The first attempt fails, so the retry is recorded too
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err := w.Write(fullBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer svr.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	cassette := NewCassette()
+	ctx := ContextWithCassette(context.Background(), cassette)
+	recorder := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithRetryPolicy(policy).WithRecording()
+	recorded, err := recorder.GetRelationFull(ctx, 301)
+	require.NoError(t, err)
+	require.Equal(t, 2, cassette.Len())
+	assert.Equal(t, http.StatusServiceUnavailable, cassette.Interactions[0].StatusCode)
+	assert.Equal(t, svr.URL+"/relation/301/full.json", cassette.Interactions[1].URL)
+	assert.Equal(t, "application/json; charset=utf-8", cassette.Interactions[1].Header["Content-Type"])
+
+	//Requests made without a cassette in the context are not recorded
+	_, err = recorder.GetRelationFull(context.Background(), 301)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cassette.Len())
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, cassette.Save(path))
+	svr.Close()
+
+	loaded, err := LoadCassette(path)
+	require.NoError(t, err)
+	player := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithRetryPolicy(policy).WithReplay(loaded)
+	replayed, err := player.GetRelationFull(context.Background(), 301)
+	require.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	_, err = player.GetRelation(context.Background(), 301)
+	assert.ErrorContains(t, err, "no recorded response for GET "+svr.URL+"/relation/301.json")
+}
+
+func Test_recordCachedWays(t *testing.T) {
+	wayBytes, err := os.ReadFile("testdata/way.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, err := w.Write(wayBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer svr.Close()
+
+	recorder := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithRecording()
+	way, err := recorder.GetWay(context.Background(), 2154620362)
+	require.NoError(t, err)
+
+	//The way is read from the cache, and saved in the cassette so that it can be replayed
+	cassette := NewCassette()
+	ctx := ContextWithCassette(context.Background(), cassette)
+	ways, errs := recorder.LoadWays(ctx, []int64{way.ID})
+	require.Empty(t, errs)
+	_, err = recorder.GetWay(ctx, way.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	require.Equal(t, 1, cassette.Len())
+	assert.Equal(t, "CACHE", cassette.Interactions[0].Method)
+	assert.Equal(t, "way/2154620362", cassette.Interactions[0].URL)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, cassette.Save(path))
+	loaded, err := LoadCassette(path)
+	require.NoError(t, err)
+
+	player := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithReplay(loaded)
+	replayed, errs := player.LoadWays(context.Background(), []int64{way.ID})
+	require.Empty(t, errs)
+	assert.Equal(t, ways, replayed)
+	assert.Equal(t, 1, requests)
+
+	_, errs = player.LoadNodes(context.Background(), []int64{101})
+	assert.ErrorContains(t, errs[101], "no recorded response")
+}
@@ -0,0 +1,163 @@
+package osm
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCache is a Cache which stores elements as JSON files, so they can be reused by later runs. Each file is named
+// after the element ID and version, e.g. way/123.v4.json, and expires once it is older than the TTL. It only saves
+// requests when members are loaded individually or with the multi-fetch endpoints, as GetRelationFull loads every member
+// anyway. On that path a member edited without editing the relation isn't seen until its cached version expires
+type DiskCache struct {
+	mu  sync.Mutex
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+func NewDiskCache(dir string, ttl time.Duration) (*DiskCache, error) {
+	for _, elemType := range []string{"node", "way", "relation"} {
+		err := os.MkdirAll(filepath.Join(dir, elemType), 0o750)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &DiskCache{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+func (d *DiskCache) GetNode(nodeId int64) (Node, bool) {
+	var node Node
+	found := d.get("node", nodeId, &node)
+	return node, found
+}
+
+func (d *DiskCache) PutNode(node Node) {
+	d.put("node", node.ID, node.Version, node)
+}
+
+func (d *DiskCache) GetWay(wayId int64) (Way, bool) {
+	var way Way
+	found := d.get("way", wayId, &way)
+	return way, found
+}
+
+func (d *DiskCache) PutWay(way Way) {
+	d.put("way", way.ID, way.Version, way)
+}
+
+func (d *DiskCache) GetRelationVersion(relationId int64) (int32, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	//Relation versions don't expire, as they are only used to detect edits to the relation
+	versions := d.findVersions("relation", relationId)
+	if len(versions) < 1 {
+		return 0, false
+	}
+	return versions[0].version, true
+}
+
+func (d *DiskCache) PutRelationVersion(relationId int64, version int32) {
+	d.put("relation", relationId, version, struct{}{})
+}
+
+func (d *DiskCache) Invalidate(elemType string, id int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.remove(d.findVersions(elemType, id))
+}
+
+func (d *DiskCache) get(elemType string, id int64, v any) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	versions := d.findVersions(elemType, id)
+	if len(versions) < 1 {
+		return false
+	}
+	latest := versions[0]
+
+	info, err := os.Stat(latest.path)
+	if err != nil {
+		return false
+	}
+	if d.ttl > 0 && d.now().Sub(info.ModTime()) > d.ttl {
+		d.remove(versions)
+		return false
+	}
+
+	bytes, err := os.ReadFile(latest.path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(bytes, v) == nil
+}
+
+func (d *DiskCache) put(elemType string, id int64, version int32, v any) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	//Remove any other versions of the element
+	others := []cachedVersion{}
+	for _, cv := range d.findVersions(elemType, id) {
+		if cv.version != version {
+			others = append(others, cv)
+		}
+	}
+	d.remove(others)
+
+	//Failing to write to the cache isn't fatal, the element will be loaded from the API next time
+	_ = os.WriteFile(d.getPath(elemType, id, version), bytes, 0o600)
+}
+
+func (d *DiskCache) getPath(elemType string, id int64, version int32) string {
+	return filepath.Join(d.dir, elemType, fmt.Sprintf("%d.v%d.json", id, version))
+}
+
+// findVersions returns the cached versions of an element, newest first
+func (d *DiskCache) findVersions(elemType string, id int64) []cachedVersion {
+	prefix := fmt.Sprintf("%d.v", id)
+	paths, err := filepath.Glob(filepath.Join(d.dir, elemType, prefix+"*.json"))
+	if err != nil {
+		return nil
+	}
+
+	versions := []cachedVersion{}
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix), ".json")
+		version, err := strconv.ParseInt(name, 10, 32)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, cachedVersion{path: path, version: int32(version)})
+	}
+	slices.SortFunc(versions, func(a, b cachedVersion) int {
+		return cmp.Compare(b.version, a.version)
+	})
+	return versions
+}
+
+func (d *DiskCache) remove(versions []cachedVersion) {
+	for _, cv := range versions {
+		_ = os.Remove(cv.path)
+	}
+}
+
+type cachedVersion struct {
+	path    string
+	version int32
+}
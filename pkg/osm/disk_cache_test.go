@@ -0,0 +1,164 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_diskCache(t *testing.T) {
+	testcases := []struct {
+		name    string
+		testFn  func(t *testing.T, cache *DiskCache)
+		checkFn func(t *testing.T, cache *DiskCache)
+	}{
+		{
+			name: "should return cached way",
+			testFn: func(t *testing.T, cache *DiskCache) {
+				cache.PutWay(Way{ID: 1, Version: 3, Nodes: []int64{10, 11}})
+			},
+			checkFn: func(t *testing.T, cache *DiskCache) {
+				way, found := cache.GetWay(1)
+				require.True(t, found)
+				assert.Equal(t, int32(3), way.Version)
+				assert.Equal(t, []int64{10, 11}, way.Nodes)
+				assert.FileExists(t, filepath.Join(cache.dir, "way", "1.v3.json"))
+			},
+		},
+		{
+			name: "should replace older version of element",
+			testFn: func(t *testing.T, cache *DiskCache) {
+				cache.PutNode(Node{ID: 1, Version: 1, Lat: 55})
+				cache.PutNode(Node{ID: 1, Version: 2, Lat: 56})
+			},
+			checkFn: func(t *testing.T, cache *DiskCache) {
+				node, found := cache.GetNode(1)
+				require.True(t, found)
+				assert.Equal(t, int32(2), node.Version)
+				assert.NoFileExists(t, filepath.Join(cache.dir, "node", "1.v1.json"))
+			},
+		},
+		{
+			name: "should expire elements older than TTL",
+			testFn: func(t *testing.T, cache *DiskCache) {
+				cache.PutWay(Way{ID: 1, Version: 3})
+				cache.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+			},
+			checkFn: func(t *testing.T, cache *DiskCache) {
+				_, found := cache.GetWay(1)
+				assert.False(t, found)
+				assert.NoFileExists(t, filepath.Join(cache.dir, "way", "1.v3.json"))
+			},
+		},
+		{
+			name: "should invalidate element",
+			testFn: func(t *testing.T, cache *DiskCache) {
+				cache.PutWay(Way{ID: 1, Version: 3})
+				cache.Invalidate("way", 1)
+			},
+			checkFn: func(t *testing.T, cache *DiskCache) {
+				_, found := cache.GetWay(1)
+				assert.False(t, found)
+			},
+		},
+		{
+			name: "should store relation version",
+			testFn: func(t *testing.T, cache *DiskCache) {
+				cache.PutRelationVersion(1, 7)
+				cache.PutRelationVersion(1, 8)
+			},
+			checkFn: func(t *testing.T, cache *DiskCache) {
+				version, found := cache.GetRelationVersion(1)
+				require.True(t, found)
+				assert.Equal(t, int32(8), version)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache, err := NewDiskCache(t.TempDir(), time.Hour)
+			require.NoError(t, err)
+			tc.testFn(t, cache)
+			tc.checkFn(t, cache)
+		})
+	}
+}
+
+func Test_relationVersionChangeInvalidatesMembers(t *testing.T) {
+	bytes := []byte(`{"elements":[{"type":"relation","id":301,"version":12,"members":[{"type":"way","ref":201,"role":""}]}]}`)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/relation/301.json", r.RequestURI)
+		_, err := w.Write(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer svr.Close()
+
+	testcases := []struct {
+		name          string
+		cachedVersion int32
+		expFound      bool
+	}{
+		{name: "relation version unchanged", cachedVersion: 12, expFound: true},
+		{name: "relation version changed", cachedVersion: 11, expFound: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache, err := NewDiskCache(t.TempDir(), time.Hour)
+			require.NoError(t, err)
+			cache.PutRelationVersion(301, tc.cachedVersion)
+			cache.PutWay(Way{ID: 201, Version: 6})
+			cache.PutWay(Way{ID: 999, Version: 1})
+
+			client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithCache(cache)
+			_, err = client.GetRelation(context.Background(), 301)
+			require.NoError(t, err)
+
+			_, found := cache.GetWay(201)
+			assert.Equal(t, tc.expFound, found)
+			_, found = cache.GetWay(999)
+			assert.True(t, found)
+			version, _ := cache.GetRelationVersion(301)
+			assert.Equal(t, int32(12), version)
+		})
+	}
+}
+
+func Test_getRelationFullReplacesCachedMembers(t *testing.T) {
+	bytes := []byte(`{"elements":[{"type":"relation","id":301,"version":12,"members":[{"type":"way","ref":201,"role":""}]},{"type":"way","id":201,"version":7,"nodes":[10,11,12]}]}`)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/relation/301/full.json", r.RequestURI)
+		_, err := w.Write(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer svr.Close()
+
+	cache, err := NewDiskCache(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+	//The way was edited without editing the relation
+	cache.PutRelationVersion(301, 12)
+	cache.PutWay(Way{ID: 201, Version: 6, Nodes: []int64{10, 11}})
+
+	client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithCache(cache)
+	_, err = client.GetRelationFull(context.Background(), 301)
+	require.NoError(t, err)
+
+	way, found := cache.GetWay(201)
+	require.True(t, found)
+	assert.Equal(t, int32(7), way.Version)
+	assert.Equal(t, []int64{10, 11, 12}, way.Nodes)
+	assert.NoFileExists(t, filepath.Join(cache.dir, "way", "201.v6.json"))
+}
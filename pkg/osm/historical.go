@@ -0,0 +1,225 @@
+package osm
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// History remembers the element histories loaded by an OSMClient, so that relations can be rebuilt at several points
+// in the past without loading the same history twice
+type History struct {
+	client    *OSMClient
+	mu        sync.Mutex
+	nodes     map[int64][]ElementVersion[Node]
+	ways      map[int64][]ElementVersion[Way]
+	relations map[int64][]ElementVersion[Relation]
+}
+
+func (c *OSMClient) History() *History {
+	return &History{
+		client:    c,
+		nodes:     map[int64][]ElementVersion[Node]{},
+		ways:      map[int64][]ElementVersion[Way]{},
+		relations: map[int64][]ElementVersion[Relation]{},
+	}
+}
+
+// AsOf returns a Provider for elements as they were at a point in the past, sharing the histories already loaded
+func (h *History) AsOf(at AsOf) *HistoricalProvider {
+	return &HistoricalProvider{client: h.client, history: h, at: at}
+}
+
+func (h *History) GetNodeHistory(ctx context.Context, nodeId int64) ([]ElementVersion[Node], error) {
+	return getRememberedHistory(ctx, h, h.nodes, nodeId, h.client.GetNodeHistory)
+}
+
+func (h *History) GetWayHistory(ctx context.Context, wayId int64) ([]ElementVersion[Way], error) {
+	return getRememberedHistory(ctx, h, h.ways, wayId, h.client.GetWayHistory)
+}
+
+func (h *History) GetRelationHistory(ctx context.Context, relationId int64) ([]ElementVersion[Relation], error) {
+	return getRememberedHistory(ctx, h, h.relations, relationId, h.client.GetRelationHistory)
+}
+
+func getRememberedHistory[T any](
+	ctx context.Context,
+	h *History,
+	histories map[int64][]ElementVersion[T],
+	id int64,
+	getHistory func(ctx context.Context, id int64) ([]ElementVersion[T], error),
+) ([]ElementVersion[T], error) {
+	h.mu.Lock()
+	versions, found := histories[id]
+	h.mu.Unlock()
+	if found {
+		return versions, nil
+	}
+
+	versions, err := getHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	histories[id] = versions
+	h.mu.Unlock()
+	return versions, nil
+}
+
+// HistoricalProvider is a Provider which returns elements as they were at a point in the past. The current version of
+// each element is loaded first, and its history is only loaded if it has been edited since
+type HistoricalProvider struct {
+	client  *OSMClient
+	history *History
+	at      AsOf
+}
+
+// AsOf returns a Provider for elements as they were at a point in the past
+func (c *OSMClient) AsOf(at AsOf) *HistoricalProvider {
+	return c.History().AsOf(at)
+}
+
+func (p *HistoricalProvider) GetRelation(ctx context.Context, relationId int64) (Relation, error) {
+	relation, err := p.client.GetRelation(ctx, relationId)
+	if err == nil && p.at.includes(relation.Timestamp, relation.Changeset) {
+		return relation, nil
+	}
+	if err != nil && !IsDeleted(err) {
+		return Relation{}, err
+	}
+
+	versions, err := p.history.GetRelationHistory(ctx, relationId)
+	if err != nil {
+		return Relation{}, err
+	}
+	return versionAt(versions, p.at)
+}
+
+// GetRelationFull returns a relation with its member ways and nodes. Members which did not exist at the time are left
+// out, as they would be by the OSM API
+func (p *HistoricalProvider) GetRelationFull(ctx context.Context, relationId int64) (FullRelation, error) {
+	relation, err := p.GetRelation(ctx, relationId)
+	if err != nil {
+		return FullRelation{}, err
+	}
+
+	wayIds := []int64{}
+	nodeIds := []int64{}
+	for _, member := range relation.Members {
+		switch member.Type {
+		case "way":
+			wayIds = append(wayIds, member.Ref)
+		case "node":
+			nodeIds = append(nodeIds, member.Ref)
+		}
+	}
+
+	full := FullRelation{Relation: relation, Ways: map[int64]Way{}, Nodes: map[int64]Node{}}
+	ways, _ := p.LoadWays(ctx, wayIds)
+	for _, way := range ways {
+		full.Ways[way.ID] = *way
+		nodeIds = append(nodeIds, way.Nodes...)
+	}
+	nodes, _ := p.LoadNodes(ctx, nodeIds)
+	for _, node := range nodes {
+		full.Nodes[node.ID] = *node
+	}
+	return full, nil
+}
+
+// GetRelationRelations returns the relations which had the relation as a member. Only relations which still have it
+// as a member are found
+func (p *HistoricalProvider) GetRelationRelations(ctx context.Context, relationId int64) ([]Relation, error) {
+	return p.GetParentRelations(ctx, "relation", relationId)
+}
+
+// GetParentRelations returns the relations which had a node, way or relation as a member. Only relations which still
+// have it as a member are found
+func (p *HistoricalProvider) GetParentRelations(ctx context.Context, elemType string, id int64) ([]Relation, error) {
+	current, err := p.client.GetParentRelations(ctx, elemType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	relations := []Relation{}
+	for _, c := range current {
+		relation, err := p.GetRelation(ctx, c.ID)
+		if IsDeleted(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		isMember := slices.ContainsFunc(relation.Members, func(m Member) bool {
+			return m.Type == elemType && m.Ref == id
+		})
+		if isMember {
+			relations = append(relations, relation)
+		}
+	}
+	return relations, nil
+}
+
+func (p *HistoricalProvider) LoadWays(ctx context.Context, wayIds []int64) (map[int64]*Way, map[int64]error) {
+	current, errs := p.client.LoadWays(ctx, wayIds)
+	return loadAsOf(ctx, p.at, wayIds, current, errs, func(w *Way) (time.Time, int64) {
+		return w.Timestamp, w.Changeset
+	}, p.history.GetWayHistory)
+}
+
+func (p *HistoricalProvider) LoadNodes(ctx context.Context, nodeIds []int64) (map[int64]*Node, map[int64]error) {
+	current, errs := p.client.LoadNodes(ctx, nodeIds)
+	return loadAsOf(ctx, p.at, nodeIds, current, errs, func(n *Node) (time.Time, int64) {
+		return n.Timestamp, n.Changeset
+	}, p.history.GetNodeHistory)
+}
+
+// loadAsOf keeps the current versions of elements which have not been edited since the point in the past, and loads
+// the history of the rest
+func loadAsOf[T any](
+	ctx context.Context,
+	at AsOf,
+	ids []int64,
+	current map[int64]*T,
+	currentErrs map[int64]error,
+	getMeta func(*T) (time.Time, int64),
+	getHistory func(ctx context.Context, id int64) ([]ElementVersion[T], error),
+) (map[int64]*T, map[int64]error) {
+	elements := map[int64]*T{}
+	errs := map[int64]error{}
+
+	for _, id := range ids {
+		if _, done := elements[id]; done {
+			continue
+		}
+		if _, done := errs[id]; done {
+			continue
+		}
+
+		if e, found := current[id]; found {
+			timestamp, changeset := getMeta(e)
+			if at.includes(timestamp, changeset) {
+				elements[id] = e
+				continue
+			}
+		}
+		if err, found := currentErrs[id]; found && !IsDeleted(err) {
+			errs[id] = err
+			continue
+		}
+
+		versions, err := getHistory(ctx, id)
+		if err != nil {
+			errs[id] = err
+			continue
+		}
+		e, err := versionAt(versions, at)
+		if err != nil {
+			errs[id] = err
+			continue
+		}
+		elements[id] = &e
+	}
+	return elements, errs
+}
@@ -0,0 +1,180 @@
+package osm
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+var errHistoryUnsupported = errors.New("element history is only available from the OSM API")
+
+// ElementVersion is one version of an element from its history
+type ElementVersion[T any] struct {
+	Element   T
+	Version   int32
+	Timestamp time.Time
+	Changeset int64
+	User      string
+	// Deleted is true for the version which deleted the element
+	Deleted bool
+}
+
+// AsOf is a point in the past, either a time or a changeset. If Changeset is set, the edits made in that changeset
+// and all earlier changesets are included
+type AsOf struct {
+	Time      time.Time
+	Changeset int64
+}
+
+func (a AsOf) String() string {
+	if a.Changeset > 0 {
+		return fmt.Sprintf("changeset %d", a.Changeset)
+	}
+	return a.Time.Format(time.RFC3339)
+}
+
+func (a AsOf) includes(timestamp time.Time, changeset int64) bool {
+	if a.Changeset > 0 {
+		return changeset <= a.Changeset
+	}
+	return !timestamp.After(a.Time)
+}
+
+// GetNodeHistory returns every version of a node, oldest first
+func (c *OSMClient) GetNodeHistory(ctx context.Context, nodeId int64) ([]ElementVersion[Node], error) {
+	res, err := c.getHistory(ctx, "node", nodeId)
+	if err != nil {
+		return nil, err
+	}
+	return getVersions(res.nodes, res.deletedNodes, func(n Node) ElementVersion[Node] {
+		return ElementVersion[Node]{
+			Element: n, Version: n.Version, Timestamp: n.Timestamp, Changeset: n.Changeset, User: n.User,
+		}
+	}), nil
+}
+
+// GetWayHistory returns every version of a way, oldest first
+func (c *OSMClient) GetWayHistory(ctx context.Context, wayId int64) ([]ElementVersion[Way], error) {
+	res, err := c.getHistory(ctx, "way", wayId)
+	if err != nil {
+		return nil, err
+	}
+	return getVersions(res.ways, res.deletedWays, func(w Way) ElementVersion[Way] {
+		return ElementVersion[Way]{
+			Element: w, Version: w.Version, Timestamp: w.Timestamp, Changeset: w.Changeset, User: w.User,
+		}
+	}), nil
+}
+
+// GetRelationHistory returns every version of a relation, oldest first
+func (c *OSMClient) GetRelationHistory(ctx context.Context, relationId int64) ([]ElementVersion[Relation], error) {
+	res, err := c.getHistory(ctx, "relation", relationId)
+	if err != nil {
+		return nil, err
+	}
+	return getVersions(res.relations, res.deletedRelations, func(r Relation) ElementVersion[Relation] {
+		return ElementVersion[Relation]{
+			Element: r, Version: r.Version, Timestamp: r.Timestamp, Changeset: r.Changeset, User: r.User,
+		}
+	}), nil
+}
+
+// GetNodeVersion returns a single version of a node. If the version deleted the node, it fails with HTTP status 410
+func (c *OSMClient) GetNodeVersion(ctx context.Context, nodeId int64, version int32) (Node, error) {
+	res, err := c.getVersion(ctx, "node", nodeId, version)
+	if err != nil {
+		return Node{}, err
+	}
+	if len(res.nodes) < 1 {
+		return Node{}, HttpStatusError{StatusCode: getMissingVersionStatus(res)}
+	}
+	return res.nodes[0], nil
+}
+
+// GetWayVersion returns a single version of a way. If the version deleted the way, it fails with HTTP status 410
+func (c *OSMClient) GetWayVersion(ctx context.Context, wayId int64, version int32) (Way, error) {
+	res, err := c.getVersion(ctx, "way", wayId, version)
+	if err != nil {
+		return Way{}, err
+	}
+	if len(res.ways) < 1 {
+		return Way{}, HttpStatusError{StatusCode: getMissingVersionStatus(res)}
+	}
+	return res.ways[0], nil
+}
+
+// GetRelationVersion returns a single version of a relation. If the version deleted the relation, it fails with HTTP
+// status 410
+func (c *OSMClient) GetRelationVersion(ctx context.Context, relationId int64, version int32) (Relation, error) {
+	res, err := c.getVersion(ctx, "relation", relationId, version)
+	if err != nil {
+		return Relation{}, err
+	}
+	if len(res.relations) < 1 {
+		return Relation{}, HttpStatusError{StatusCode: getMissingVersionStatus(res)}
+	}
+	return res.relations[0], nil
+}
+
+func (c *OSMClient) getHistory(ctx context.Context, elemType string, id int64) (*response, error) {
+	hs, ok := c.source.(historySource)
+	if !ok {
+		return nil, errHistoryUnsupported
+	}
+	return c.get(ctx, hs.history(elemType, id))
+}
+
+func (c *OSMClient) getVersion(ctx context.Context, elemType string, id int64, version int32) (*response, error) {
+	hs, ok := c.source.(historySource)
+	if !ok {
+		return nil, errHistoryUnsupported
+	}
+	return c.get(ctx, hs.version(elemType, id, version))
+}
+
+func getMissingVersionStatus(res *response) int {
+	if len(res.hidden) > 0 {
+		return http.StatusGone
+	}
+	return http.StatusNotFound
+}
+
+func getVersions[T any](visible []T, deleted []T, toVersion func(T) ElementVersion[T]) []ElementVersion[T] {
+	versions := []ElementVersion[T]{}
+	for _, e := range visible {
+		versions = append(versions, toVersion(e))
+	}
+	for _, e := range deleted {
+		v := toVersion(e)
+		v.Deleted = true
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, func(a, b ElementVersion[T]) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return versions
+}
+
+// versionAt returns an element as it was at a point in its history. It fails with HTTP status 404 if the element had
+// not been created yet, or 410 if it had been deleted, matching the OSM API
+func versionAt[T any](versions []ElementVersion[T], at AsOf) (T, error) {
+	var found *ElementVersion[T]
+	for i, v := range versions {
+		if at.includes(v.Timestamp, v.Changeset) {
+			found = &versions[i]
+		}
+	}
+
+	var zero T
+	if found == nil {
+		return zero, HttpStatusError{StatusCode: http.StatusNotFound}
+	}
+	if found.Deleted {
+		return zero, HttpStatusError{StatusCode: http.StatusGone}
+	}
+	return found.Element, nil
+}
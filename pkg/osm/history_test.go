@@ -0,0 +1,149 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var historyResponses = map[string]string{
+	"/relation/301.json": `{"elements": [
+		{"type": "relation", "id": 301, "version": 12, "timestamp": "2023-11-12T14:14:44Z", "changeset": 143935023,
+			"members": [{"type": "node", "ref": 101, "role": "stop"}, {"type": "way", "ref": 201, "role": ""}],
+			"tags": {"type": "route"}}
+	]}`,
+	"/relation/301/history.json": `{"elements": [
+		{"type": "relation", "id": 301, "version": 11, "timestamp": "2022-01-01T00:00:00Z", "changeset": 105000000,
+			"members": [{"type": "way", "ref": 201, "role": ""}], "tags": {"type": "route"}},
+		{"type": "relation", "id": 301, "version": 12, "timestamp": "2023-11-12T14:14:44Z", "changeset": 143935023,
+			"members": [{"type": "node", "ref": 101, "role": "stop"}, {"type": "way", "ref": 201, "role": ""}],
+			"tags": {"type": "route"}}
+	]}`,
+	"/ways.json?ways=201": `{"elements": [
+		{"type": "way", "id": 201, "version": 7, "timestamp": "2022-05-01T10:00:00Z", "changeset": 110000003,
+			"nodes": [101, 102], "tags": {"highway": "tertiary"}}
+	]}`,
+	"/way/201/history.json": `{"elements": [
+		{"type": "way", "id": 201, "version": 6, "timestamp": "2021-06-01T00:00:00Z", "changeset": 100000005,
+			"nodes": [101, 102], "tags": {"highway": "residential"}},
+		{"type": "way", "id": 201, "version": 7, "timestamp": "2022-05-01T10:00:00Z", "changeset": 110000003,
+			"nodes": [101, 102], "tags": {"highway": "tertiary"}}
+	]}`,
+	"/way/201/6.json": `{"elements": [
+		{"type": "way", "id": 201, "version": 6, "timestamp": "2021-06-01T00:00:00Z", "changeset": 100000005,
+			"nodes": [101, 102], "tags": {"highway": "residential"}}
+	]}`,
+	"/nodes.json?nodes=101,102": `{"elements": [
+		{"type": "node", "id": 101, "version": 1, "timestamp": "2021-03-04T05:06:07Z", "changeset": 100000001, "lat": 55.92, "lon": -3.28},
+		{"type": "node", "id": 102, "version": 2, "timestamp": "2023-03-04T05:06:07Z", "changeset": 120000001, "visible": false}
+	]}`,
+	"/node/102/history.json": `{"elements": [
+		{"type": "node", "id": 102, "version": 1, "timestamp": "2020-01-01T00:00:00Z", "changeset": 90000000, "lat": 55.93, "lon": -3.29},
+		{"type": "node", "id": 102, "version": 2, "timestamp": "2023-03-04T05:06:07Z", "changeset": 120000001, "visible": false}
+	]}`,
+}
+
+func Test_asOf(t *testing.T) {
+	testcases := []struct {
+		name       string
+		at         AsOf
+		expHistory int
+		checkFn    func(t *testing.T, full FullRelation, err error)
+	}{
+		{
+			name:       "should return current elements if they have not been edited since",
+			at:         AsOf{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expHistory: 1,
+			checkFn: func(t *testing.T, full FullRelation, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int32(12), full.Relation.Version)
+				assert.Equal(t, int32(7), full.Ways[201].Version)
+				//Node 102 had already been deleted
+				assert.Len(t, full.Nodes, 1)
+			},
+		},
+		{
+			name:       "should rebuild elements from their history",
+			at:         AsOf{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expHistory: 3,
+			checkFn: func(t *testing.T, full FullRelation, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int32(11), full.Relation.Version)
+				assert.Len(t, full.Relation.Members, 1)
+				assert.Equal(t, "residential", full.Ways[201].Tags["highway"])
+				assert.Equal(t, int32(1), full.Nodes[101].Version)
+				//Node 102 was deleted later
+				assert.Equal(t, int32(1), full.Nodes[102].Version)
+			},
+		},
+		{
+			name:       "should use versions up to and including changeset",
+			at:         AsOf{Changeset: 110000003},
+			expHistory: 2,
+			checkFn: func(t *testing.T, full FullRelation, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int32(11), full.Relation.Version)
+				assert.Equal(t, "tertiary", full.Ways[201].Tags["highway"])
+			},
+		},
+		{
+			name:       "should return not found if relation had not been created",
+			at:         AsOf{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expHistory: 1,
+			checkFn: func(t *testing.T, full FullRelation, err error) {
+				assert.EqualError(t, err, "HTTP status code 404")
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			historyRequests := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, found := historyResponses[r.RequestURI]
+				if !found {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if strings.Contains(r.RequestURI, "/history") {
+					historyRequests++
+				}
+				_, err := w.Write([]byte(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}))
+			defer svr.Close()
+
+			client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
+			full, err := client.AsOf(tc.at).GetRelationFull(context.Background(), 301)
+			tc.checkFn(t, full, err)
+			assert.Equal(t, tc.expHistory, historyRequests)
+		})
+	}
+}
+
+func Test_getVersion(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(historyResponses[r.RequestURI]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer svr.Close()
+
+	client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
+	way, err := client.GetWayVersion(context.Background(), 201, 6)
+	require.NoError(t, err)
+	assert.Equal(t, "residential", way.Tags["highway"])
+	assert.Equal(t, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), way.Timestamp)
+
+	_, err = client.WithOverpass(svr.URL).GetWayVersion(context.Background(), 201, 6)
+	assert.ErrorIs(t, err, errHistoryUnsupported)
+}
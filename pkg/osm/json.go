@@ -0,0 +1,66 @@
+package osm
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// DecodeJSON reads the elements from an OSM API JSON response, e.g. a saved copy of /relation/{id}/full.json
+func DecodeJSON(r io.Reader) (*Store, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	store := newStore()
+	err = readJSON(data, store)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func readJSON(data []byte, sink elementSink) error {
+	var res struct {
+		Elements []json.RawMessage `json:"elements"`
+		Remark   string            `json:"remark"`
+	}
+	err := json.Unmarshal(data, &res)
+	if err != nil {
+		return err
+	}
+	if res.Remark != "" {
+		return OverpassRemarkError{Remark: strings.TrimSpace(res.Remark)}
+	}
+
+	for _, raw := range res.Elements {
+		var elem struct {
+			Type    string `json:"type"`
+			Visible *bool  `json:"visible"`
+		}
+		err = json.Unmarshal(raw, &elem)
+		if err != nil {
+			return err
+		}
+		visible := elem.Visible == nil || *elem.Visible
+
+		switch elem.Type {
+		case "node":
+			var node Node
+			err = json.Unmarshal(raw, &node)
+			sink.addNode(node, visible)
+		case "way":
+			var way Way
+			err = json.Unmarshal(raw, &way)
+			sink.addWay(way, visible)
+		case "relation":
+			var relation Relation
+			err = json.Unmarshal(raw, &relation)
+			sink.addRelation(relation, visible)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package osm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit caps the requests made by an OSMClient across all of its methods and goroutines
+type RateLimit struct {
+	// RequestsPerSecond is the sustained request rate. Zero or less disables the rate limit
+	RequestsPerSecond float64
+	// Burst is the number of requests which can be made at once before the rate limit applies
+	Burst int
+	// MaxConcurrent is the maximum number of requests in flight at once. Zero or less disables the limit
+	MaxConcurrent int
+}
+
+func DefaultRateLimit() RateLimit {
+	return RateLimit{RequestsPerSecond: 10, Burst: 10, MaxConcurrent: 4}
+}
+
+// limiter is a token bucket combined with a semaphore for concurrent connections
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	sem    chan struct{}
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	l := &limiter{rate: limit.RequestsPerSecond, burst: float64(max(limit.Burst, 1))}
+	l.tokens = l.burst
+	l.last = time.Now()
+	if limit.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire blocks until a request can be made. It returns a function to release the connection slot and how long the
+// caller was throttled for
+func (l *limiter) acquire(ctx context.Context) (func(), time.Duration, error) {
+	start := time.Now()
+
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, time.Since(start), ctx.Err()
+		}
+	}
+	release := func() {
+		if l.sem != nil {
+			<-l.sem
+		}
+	}
+
+	err := sleepCtx(ctx, l.reserve())
+	if err != nil {
+		release()
+		return nil, time.Since(start), err
+	}
+	return release, time.Since(start), nil
+}
+
+// reserve takes a token from the bucket and returns how long to wait until the token is available
+func (l *limiter) reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
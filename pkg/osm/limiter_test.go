@@ -0,0 +1,97 @@
+package osm
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rateLimit(t *testing.T) {
+	wayBytes, err := os.ReadFile("testdata/way.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name      string
+		limit     RateLimit
+		handlerFn func(w http.ResponseWriter, r *http.Request)
+		checkFn   func(t *testing.T, elapsed time.Duration, maxInFlight int32, logs string)
+	}{
+		{
+			name:  "should limit request rate across goroutines",
+			limit: RateLimit{RequestsPerSecond: 50, Burst: 1},
+			checkFn: func(t *testing.T, elapsed time.Duration, maxInFlight int32, logs string) {
+				//First request uses the burst, then 5 more at 20ms intervals
+				assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond)
+				assert.Contains(t, logs, "OSM request throttled")
+			},
+		},
+		{
+			name:  "should limit concurrent connections",
+			limit: RateLimit{MaxConcurrent: 2},
+			handlerFn: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(20 * time.Millisecond)
+			},
+			checkFn: func(t *testing.T, elapsed time.Duration, maxInFlight int32, logs string) {
+				assert.Equal(t, int32(2), maxInFlight)
+			},
+		},
+		{
+			name:  "should not throttle when unlimited",
+			limit: RateLimit{},
+			checkFn: func(t *testing.T, elapsed time.Duration, maxInFlight int32, logs string) {
+				assert.NotContains(t, logs, "OSM request throttled")
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var inFlight, maxInFlight atomic.Int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					m := maxInFlight.Load()
+					if n <= m || maxInFlight.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				if tc.handlerFn != nil {
+					tc.handlerFn(w, r)
+				}
+				_, err := w.Write(wayBytes)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}))
+			defer svr.Close()
+
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+			client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithRateLimit(tc.limit).WithLogger(logger)
+
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := int64(0); i < 6; i++ {
+				wg.Go(func() {
+					_, err := client.GetRelationRelations(context.Background(), i)
+					require.NoError(t, err)
+				})
+			}
+			wg.Wait()
+			tc.checkFn(t, time.Since(start), maxInFlight.Load(), logs.String())
+		})
+	}
+}
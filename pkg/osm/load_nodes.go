@@ -2,46 +2,72 @@ package osm
 
 import (
 	"context"
+	"fmt"
 )
 
-func (c *OSMClient) LoadNodes(ctx context.Context, nodeIds []int64) map[int64]*Node {
-	ch := make(chan nodeResult, len(nodeIds))
+// LoadNodes loads nodes from the cache, or in bulk from the API. Any nodes which could not be loaded are returned in
+// the error map instead, e.g. an HttpStatusError with status code 410 for a deleted node
+func (c *OSMClient) LoadNodes(ctx context.Context, nodeIds []int64) (map[int64]*Node, map[int64]error) {
 	nodeMap := map[int64]*Node{}
+	errs := map[int64]error{}
+	toFetch := []int64{}
+	seen := map[int64]bool{}
+
+	for _, nodeId := range nodeIds {
+		if seen[nodeId] {
+			continue
+		}
+		seen[nodeId] = true
+		node, found := c.getCachedNode(ctx, nodeId)
+		if found {
+			nodeMap[nodeId] = &node
+			continue
+		}
+		toFetch = append(toFetch, nodeId)
+	}
+
+	chunks := chunkIds(toFetch)
+	ch := make(chan nodesResult, len(chunks))
 
 	remaining := 0
-	for idx, wayId := range nodeIds {
-		go loadNode(ctx, c, wayId, ch)
+	for idx, chunk := range chunks {
+		go loadNodes(ctx, c, chunk, ch)
 		remaining++
 		if idx >= c.parallelReqs {
 			//Wait before starting next request
-			nodeResult := <-ch
+			addNodeResult(nodeMap, errs, <-ch)
 			remaining--
-			nodeMap[nodeResult.nodeID] = nodeResult.node
 		}
 	}
 	for i := 0; i < remaining; i++ {
-		wayResult := <-ch
-		nodeMap[wayResult.nodeID] = wayResult.node
+		addNodeResult(nodeMap, errs, <-ch)
 	}
-	return nodeMap
+	return nodeMap, errs
+}
+
+func loadNodes(ctx context.Context, client *OSMClient, nodeIds []int64, c chan nodesResult) {
+	nodes, errs, err := client.getNodesChunk(ctx, nodeIds)
+	c <- nodesResult{nodeIds: nodeIds, nodes: nodes, errs: errs, err: err}
 }
 
-func loadNode(ctx context.Context, client *OSMClient, wayId int64, c chan nodeResult) {
-	node, err := client.GetNode(ctx, wayId)
-	if err != nil {
-		c <- nodeResult{
-			nodeID: wayId,
-			node:   nil,
+func addNodeResult(nodeMap map[int64]*Node, errs map[int64]error, result nodesResult) {
+	if result.err != nil {
+		for _, nodeId := range result.nodeIds {
+			errs[nodeId] = fmt.Errorf("failed to load node %d: %w", nodeId, result.err)
 		}
 		return
 	}
-	c <- nodeResult{
-		nodeID: wayId,
-		node:   &node,
+	for _, node := range result.nodes {
+		nodeMap[node.ID] = &node
+	}
+	for nodeId, err := range result.errs {
+		errs[nodeId] = err
 	}
 }
 
-type nodeResult struct {
-	nodeID int64
-	node   *Node
+type nodesResult struct {
+	nodeIds []int64
+	nodes   []Node
+	errs    map[int64]error
+	err     error
 }
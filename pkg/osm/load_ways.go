@@ -2,46 +2,72 @@ package osm
 
 import (
 	"context"
+	"fmt"
 )
 
-func (c *OSMClient) LoadWays(ctx context.Context, wayIds []int64) map[int64]*Way {
-	ch := make(chan wayResult, len(wayIds))
+// LoadWays loads ways from the cache, or in bulk from the API. Any ways which could not be loaded are returned in
+// the error map instead, e.g. an HttpStatusError with status code 410 for a deleted way
+func (c *OSMClient) LoadWays(ctx context.Context, wayIds []int64) (map[int64]*Way, map[int64]error) {
 	wayMap := map[int64]*Way{}
+	errs := map[int64]error{}
+	toFetch := []int64{}
+	seen := map[int64]bool{}
+
+	for _, wayId := range wayIds {
+		if seen[wayId] {
+			continue
+		}
+		seen[wayId] = true
+		way, found := c.getCachedWay(ctx, wayId)
+		if found {
+			wayMap[wayId] = &way
+			continue
+		}
+		toFetch = append(toFetch, wayId)
+	}
+
+	chunks := chunkIds(toFetch)
+	ch := make(chan waysResult, len(chunks))
 
 	remaining := 0
-	for idx, wayId := range wayIds {
-		go loadWay(ctx, c, wayId, ch)
+	for idx, chunk := range chunks {
+		go loadWays(ctx, c, chunk, ch)
 		remaining++
 		if idx >= c.parallelReqs {
 			//Wait before starting next request
-			wayResult := <-ch
+			addWayResult(wayMap, errs, <-ch)
 			remaining--
-			wayMap[wayResult.WayID] = wayResult.Way
 		}
 	}
 	for i := 0; i < remaining; i++ {
-		wayResult := <-ch
-		wayMap[wayResult.WayID] = wayResult.Way
+		addWayResult(wayMap, errs, <-ch)
 	}
-	return wayMap
+	return wayMap, errs
+}
+
+func loadWays(ctx context.Context, client *OSMClient, wayIds []int64, c chan waysResult) {
+	ways, errs, err := client.getWaysChunk(ctx, wayIds)
+	c <- waysResult{wayIds: wayIds, ways: ways, errs: errs, err: err}
 }
 
-func loadWay(ctx context.Context, client *OSMClient, wayId int64, c chan wayResult) {
-	way, err := client.GetWay(ctx, wayId)
-	if err != nil {
-		c <- wayResult{
-			WayID: wayId,
-			Way:   nil,
+func addWayResult(wayMap map[int64]*Way, errs map[int64]error, result waysResult) {
+	if result.err != nil {
+		for _, wayId := range result.wayIds {
+			errs[wayId] = fmt.Errorf("failed to load way %d: %w", wayId, result.err)
 		}
 		return
 	}
-	c <- wayResult{
-		WayID: wayId,
-		Way:   &way,
+	for _, way := range result.ways {
+		wayMap[way.ID] = &way
+	}
+	for wayId, err := range result.errs {
+		errs[wayId] = err
 	}
 }
 
-type wayResult struct {
-	WayID int64
-	Way   *Way
+type waysResult struct {
+	wayIds []int64
+	ways   []Way
+	errs   map[int64]error
+	err    error
 }
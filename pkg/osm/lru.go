@@ -0,0 +1,83 @@
+package osm
+
+import (
+	"container/list"
+	"time"
+)
+
+// lru is a least-recently-used cache with a maximum number of entries, where each entry also expires after maxAge.
+// It is not safe for concurrent use
+type lru[V any] struct {
+	maxEntries int
+	maxAge     time.Duration
+	now        func() time.Time
+	ll         *list.List
+	items      map[int64]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   int64
+	value V
+	added time.Time
+}
+
+func newLRU[V any](maxEntries int, maxAge time.Duration, now func() time.Time) *lru[V] {
+	return &lru[V]{
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+		now:        now,
+		ll:         list.New(),
+		items:      map[int64]*list.Element{},
+	}
+}
+
+// get returns the value for the key, and whether it was found. The returned bool is false for expired entries
+func (l *lru[V]) get(key int64) (V, bool) {
+	var zero V
+	elem, found := l.items[key]
+	if !found {
+		return zero, false
+	}
+	entry := elem.Value.(*lruEntry[V])
+	if l.maxAge > 0 && l.now().Sub(entry.added) > l.maxAge {
+		l.removeElement(elem)
+		return zero, false
+	}
+	l.ll.MoveToFront(elem)
+	return entry.value, true
+}
+
+// put adds or replaces the value for the key, and returns the number of entries evicted to make room for it
+func (l *lru[V]) put(key int64, value V) int {
+	if elem, found := l.items[key]; found {
+		entry := elem.Value.(*lruEntry[V])
+		entry.value = value
+		entry.added = l.now()
+		l.ll.MoveToFront(elem)
+		return 0
+	}
+
+	l.items[key] = l.ll.PushFront(&lruEntry[V]{key: key, value: value, added: l.now()})
+
+	evicted := 0
+	for l.maxEntries > 0 && l.ll.Len() > l.maxEntries {
+		l.removeElement(l.ll.Back())
+		evicted++
+	}
+	return evicted
+}
+
+func (l *lru[V]) remove(key int64) {
+	if elem, found := l.items[key]; found {
+		l.removeElement(elem)
+	}
+}
+
+func (l *lru[V]) len() int {
+	return l.ll.Len()
+}
+
+func (l *lru[V]) removeElement(elem *list.Element) {
+	l.ll.Remove(elem)
+	delete(l.items, elem.Value.(*lruEntry[V]).key)
+}
@@ -0,0 +1,202 @@
+package osm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxMultiFetchIdsLength keeps multi-fetch requests well below the URL length limits of the API and any proxies
+const maxMultiFetchIdsLength = 1800
+
+// GetWays fetches ways using the /ways multi-fetch endpoint. IDs which do not exist, or have been deleted, are
+// returned as missing rather than as an error
+func (c *OSMClient) GetWays(ctx context.Context, wayIds []int64) ([]Way, []int64, error) {
+	ways := []Way{}
+	missing := []int64{}
+
+	for _, chunk := range chunkIds(wayIds) {
+		chunkWays, chunkErrs, err := c.getWaysChunk(ctx, chunk)
+		if err != nil {
+			return nil, nil, err
+		}
+		ways = append(ways, chunkWays...)
+		chunkMissing, err := getMissingIds(chunk, chunkErrs)
+		if err != nil {
+			return nil, nil, err
+		}
+		missing = append(missing, chunkMissing...)
+	}
+	return ways, missing, nil
+}
+
+// getWaysChunk loads a chunk of ways with a single request. If any ways cannot be loaded, the error for each of them
+// is returned in the map
+func (c *OSMClient) getWaysChunk(ctx context.Context, wayIds []int64) ([]Way, map[int64]error, error) {
+	res, err := c.get(ctx, c.source.ways(wayIds))
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			//At least one of the ways never existed - fall back to loading them one at a time
+			ways, errs := c.getWaysIndividually(ctx, wayIds)
+			return ways, errs, nil
+		}
+		return nil, nil, err
+	}
+
+	hidden := res.hiddenIds("way")
+	ways := []Way{}
+	for _, way := range res.ways {
+		c.cacheWay(way)
+		ways = append(ways, way)
+	}
+	return ways, getMultiFetchErrors(wayIds, hidden, ways, func(w Way) int64 { return w.ID }), nil
+}
+
+func (c *OSMClient) getWaysIndividually(ctx context.Context, wayIds []int64) ([]Way, map[int64]error) {
+	ways := []Way{}
+	errs := map[int64]error{}
+	for _, wayId := range wayIds {
+		way, err := c.GetWay(ctx, wayId)
+		if err != nil {
+			errs[wayId] = err
+			continue
+		}
+		ways = append(ways, way)
+	}
+	return ways, errs
+}
+
+// GetNodes fetches nodes using the /nodes multi-fetch endpoint. IDs which do not exist, or have been deleted, are
+// returned as missing rather than as an error
+func (c *OSMClient) GetNodes(ctx context.Context, nodeIds []int64) ([]Node, []int64, error) {
+	nodes := []Node{}
+	missing := []int64{}
+
+	for _, chunk := range chunkIds(nodeIds) {
+		chunkNodes, chunkErrs, err := c.getNodesChunk(ctx, chunk)
+		if err != nil {
+			return nil, nil, err
+		}
+		nodes = append(nodes, chunkNodes...)
+		chunkMissing, err := getMissingIds(chunk, chunkErrs)
+		if err != nil {
+			return nil, nil, err
+		}
+		missing = append(missing, chunkMissing...)
+	}
+	return nodes, missing, nil
+}
+
+// getNodesChunk loads a chunk of nodes with a single request. If any nodes cannot be loaded, the error for each of
+// them is returned in the map
+func (c *OSMClient) getNodesChunk(ctx context.Context, nodeIds []int64) ([]Node, map[int64]error, error) {
+	res, err := c.get(ctx, c.source.nodes(nodeIds))
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			//At least one of the nodes never existed - fall back to loading them one at a time
+			nodes, errs := c.getNodesIndividually(ctx, nodeIds)
+			return nodes, errs, nil
+		}
+		return nil, nil, err
+	}
+
+	hidden := res.hiddenIds("node")
+	nodes := []Node{}
+	for _, node := range res.nodes {
+		c.cacheNode(node)
+		nodes = append(nodes, node)
+	}
+	return nodes, getMultiFetchErrors(nodeIds, hidden, nodes, func(n Node) int64 { return n.ID }), nil
+}
+
+func (c *OSMClient) getNodesIndividually(ctx context.Context, nodeIds []int64) ([]Node, map[int64]error) {
+	nodes := []Node{}
+	errs := map[int64]error{}
+	for _, nodeId := range nodeIds {
+		node, err := c.GetNode(ctx, nodeId)
+		if err != nil {
+			errs[nodeId] = err
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, errs
+}
+
+// chunkIds splits IDs into chunks which keep the comma-separated list of IDs below maxMultiFetchIdsLength
+func chunkIds(ids []int64) [][]int64 {
+	chunks := [][]int64{}
+	chunk := []int64{}
+	length := 0
+
+	for _, id := range ids {
+		idLength := len(strconv.FormatInt(id, 10)) + 1
+		if len(chunk) > 0 && length+idLength > maxMultiFetchIdsLength {
+			chunks = append(chunks, chunk)
+			chunk = []int64{}
+			length = 0
+		}
+		chunk = append(chunk, id)
+		length += idLength
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func joinIds(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// getMultiFetchErrors returns an error for each ID which was deleted (returned with visible=false), or was missing
+// from a multi-fetch response
+func getMultiFetchErrors[T any](ids []int64, hidden map[int64]bool, found []T, getId func(T) int64) map[int64]error {
+	foundIds := map[int64]bool{}
+	for _, f := range found {
+		foundIds[getId(f)] = true
+	}
+	errs := map[int64]error{}
+	for _, id := range ids {
+		if hidden[id] {
+			errs[id] = HttpStatusError{StatusCode: http.StatusGone}
+		} else if !foundIds[id] {
+			errs[id] = HttpStatusError{StatusCode: http.StatusNotFound}
+		}
+	}
+	return errs
+}
+
+// getMissingIds returns the IDs of elements which do not exist or have been deleted. Any other error is returned
+func getMissingIds(ids []int64, errs map[int64]error) ([]int64, error) {
+	missing := []int64{}
+	for _, id := range ids {
+		err, found := errs[id]
+		if !found {
+			continue
+		}
+		if !IsDeleted(err) {
+			return nil, err
+		}
+		missing = append(missing, id)
+	}
+	return missing, nil
+}
+
+// IsDeleted returns true if the error shows that an element has been deleted (HTTP 410) or never existed (HTTP 404)
+func IsDeleted(err error) bool {
+	return isStatus(err, http.StatusGone) || isStatus(err, http.StatusNotFound)
+}
+
+func isStatus(err error, statusCode int) bool {
+	if hse, ok := errors.AsType[HttpStatusError](err); ok {
+		return hse.StatusCode == statusCode
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getWays(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/ways.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wayBytes, err := os.ReadFile("testdata/way.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name      string
+		wayIds    []int64
+		handlerFn func(t *testing.T) func(w http.ResponseWriter, r *http.Request)
+		checkFn   func(t *testing.T, ways []Way, missing []int64, err error)
+	}{
+		{
+			name:   "HTTP 200 with deleted way",
+			wayIds: []int64{2154620362, 2154620363},
+			handlerFn: func(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, "/ways.json?ways=2154620362,2154620363", r.RequestURI)
+					_, err := w.Write(bytes)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			checkFn: func(t *testing.T, ways []Way, missing []int64, err error) {
+				require.NoError(t, err)
+				require.Len(t, ways, 1)
+				assert.Equal(t, int64(2154620362), ways[0].ID)
+				assert.Equal(t, []int64{2154620363}, missing)
+			},
+		},
+		{
+			name:   "HTTP 404 falls back to individual requests",
+			wayIds: []int64{2154620362, 1},
+			handlerFn: func(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if r.RequestURI == "/way/2154620362.json" {
+						_, err := w.Write(wayBytes)
+						if err != nil {
+							t.Fatal(err)
+						}
+						return
+					}
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			checkFn: func(t *testing.T, ways []Way, missing []int64, err error) {
+				require.NoError(t, err)
+				require.Len(t, ways, 1)
+				assert.Equal(t, int64(2154620362), ways[0].ID)
+				assert.Equal(t, []int64{1}, missing)
+			},
+		},
+		{
+			name:   "HTTP 500",
+			wayIds: []int64{2154620362},
+			handlerFn: func(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+			checkFn: func(t *testing.T, ways []Way, missing []int64, err error) {
+				assert.EqualError(t, err, "HTTP status code 500")
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			handlerFn := http.HandlerFunc(tc.handlerFn(t))
+			svr := httptest.NewServer(handlerFn)
+			defer svr.Close()
+
+			client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
+			ways, missing, err := client.GetWays(context.Background(), tc.wayIds)
+			tc.checkFn(t, ways, missing, err)
+		})
+	}
+}
+
+func Test_loadWays(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/ways.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, err := w.Write(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer svr.Close()
+
+	client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
+	wayIds := []int64{2154620362, 2154620363}
+
+	ways, errs := client.LoadWays(context.Background(), wayIds)
+	require.NotNil(t, ways[2154620362])
+	assert.Nil(t, ways[2154620363])
+	assert.True(t, IsDeleted(errs[2154620363]))
+	assert.Equal(t, 1, requests)
+
+	ways, errs = client.LoadWays(context.Background(), []int64{2154620362})
+	require.NotNil(t, ways[2154620362])
+	assert.Empty(t, errs)
+	assert.Equal(t, 1, requests)
+}
+
+func Test_chunkIds(t *testing.T) {
+	ids := []int64{}
+	for i := int64(0); i < 500; i++ {
+		ids = append(ids, 1000000000+i)
+	}
+
+	chunks := chunkIds(ids)
+	require.Len(t, chunks, 4)
+
+	total := 0
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(joinIds(chunk)), maxMultiFetchIdsLength)
+		total += len(chunk)
+	}
+	assert.Equal(t, len(ids), total)
+}
+
+func Test_loadNodesErrors(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/node/1.json":
+			_, err := w.Write([]byte(`{"elements":[{"type":"node","id":1,"lat":55.9,"lon":-3.2}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+		case "/node/2.json":
+			w.WriteHeader(http.StatusGone)
+		case "/node/3.json":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
+	nodes, errs := client.LoadNodes(context.Background(), []int64{1, 2, 3, 4})
+
+	require.NotNil(t, nodes[1])
+	require.Len(t, errs, 3)
+	assert.EqualError(t, errs[2], "HTTP status code 410")
+	assert.EqualError(t, errs[3], "HTTP status code 403")
+	assert.EqualError(t, errs[4], "HTTP status code 404")
+	assert.True(t, IsDeleted(errs[2]))
+	assert.False(t, IsDeleted(errs[3]))
+}
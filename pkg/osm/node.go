@@ -1,14 +1,21 @@
 package osm
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Node struct {
-	Type    string            `json:"type"`
-	ID      int64             `json:"id"`
-	Lat     float32           `json:"lat"`
-	Lon     float32           `json:"lon"`
-	Version int32             `json:"version"`
-	Tags    map[string]string `json:"tags"`
+	Type      string            `json:"type"`
+	ID        int64             `json:"id"`
+	Lat       float64           `json:"lat"`
+	Lon       float64           `json:"lon"`
+	Version   int32             `json:"version"`
+	Timestamp time.Time         `json:"timestamp,omitzero"`
+	Changeset int64             `json:"changeset,omitempty"`
+	User      string            `json:"user,omitempty"`
+	UID       int64             `json:"uid,omitempty"`
+	Tags      map[string]string `json:"tags"`
 }
 
 func (n Node) GetTags() map[string]string {
@@ -2,11 +2,12 @@ package osm
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/v2/xray"
@@ -14,30 +15,74 @@ import (
 
 const defaultBaseUrl = "https://api.openstreetmap.org/api/0.6"
 const defaultParallelReqs = 2
+const throttleLogThreshold = 10 * time.Millisecond
 
 func NewClient(userAgent string) *OSMClient {
-	httpClient := http.Client{Timeout: time.Duration(3) * time.Second}
 	return &OSMClient{
-		httpClient:   httpClient,
-		baseUrl:      defaultBaseUrl,
-		nodeCache:    NodeCache{v: map[int64]Node{}},
-		wayCache:     WayCache{v: map[int64]Way{}},
+		httpClient:   http.Client{},
+		source:       apiSource{baseUrl: defaultBaseUrl},
+		cache:        DefaultMemoryCache(),
 		userAgent:    userAgent,
 		parallelReqs: defaultParallelReqs,
+		limiter:      newLimiter(DefaultRateLimit()),
+		logger:       slog.New(slog.DiscardHandler),
 	}
 }
 
 type OSMClient struct {
 	httpClient   http.Client
-	baseUrl      string
-	nodeCache    NodeCache
-	wayCache     WayCache
+	source       source
+	cache        Cache
 	userAgent    string
 	parallelReqs int
+	retryPolicy  *RetryPolicy
+	xml          bool
+	limiter      *limiter
+	logger       *slog.Logger
+	recording    bool
+	replay       *Cassette
 }
 
 func (c *OSMClient) WithBaseUrl(baseUrl string) *OSMClient {
-	c.baseUrl = baseUrl
+	c.source = apiSource{baseUrl: baseUrl, xml: c.xml}
+	return c
+}
+
+// WithOverpass loads elements from an Overpass API instance instead of the OSM API, e.g. DefaultOverpassUrl
+func (c *OSMClient) WithOverpass(overpassUrl string) *OSMClient {
+	c.source = overpassSource{url: overpassUrl, xml: c.xml}
+	return c
+}
+
+// WithXML requests OSM XML instead of JSON, for mirrors which only support XML. It applies to the client's source
+// whether it is set before or after this is called. Responses are decoded according to their content type either way
+func (c *OSMClient) WithXML() *OSMClient {
+	c.xml = true
+	c.source = c.source.withXML()
+	return c
+}
+
+// WithRetryPolicy replaces the retry policy of the client's source, i.e. DefaultRetryPolicy for the OSM API or
+// OverpassRetryPolicy for Overpass
+func (c *OSMClient) WithRetryPolicy(policy RetryPolicy) *OSMClient {
+	c.retryPolicy = &policy
+	return c
+}
+
+// WithRateLimit replaces the client's rate limit. The limit is shared by every request the client makes
+func (c *OSMClient) WithRateLimit(limit RateLimit) *OSMClient {
+	c.limiter = newLimiter(limit)
+	return c
+}
+
+func (c *OSMClient) WithLogger(logger *slog.Logger) *OSMClient {
+	c.logger = logger
+	return c
+}
+
+// WithCache replaces the client's default in-memory cache of nodes and ways
+func (c *OSMClient) WithCache(cache Cache) *OSMClient {
+	c.cache = cache
 	return c
 }
 
@@ -46,102 +91,119 @@ func (c *OSMClient) WithXRay() *OSMClient {
 	return c
 }
 
-func (c *OSMClient) GetRelation(ctx context.Context, relationId int64) (Relation, error) {
-	url := fmt.Sprintf("%s/relation/%d.json", c.baseUrl, relationId)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return Relation{}, err
+// WithRecording saves every response in the cassette attached to the request context with ContextWithCassette. It
+// should be called after WithXRay, as it wraps the existing transport. Nodes and ways read from the cache are saved in
+// the cassette too, so that everything the run uses can be replayed
+func (c *OSMClient) WithRecording() *OSMClient {
+	next := c.httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
 	}
-	req.Header.Set("User-Agent", c.userAgent)
+	c.httpClient.Transport = recordingTransport{next: next}
+	c.recording = true
+	return c
+}
 
-	response, err := c.httpClient.Do(req)
-	if err != nil {
-		return Relation{}, err
-	}
-	defer func() {
-		_ = response.Body.Close()
-	}()
+// WithReplay answers every request from a recorded cassette instead of making it. Nodes and ways which were read from
+// the cache when the cassette was recorded are read from the cassette if they aren't in the client's cache
+func (c *OSMClient) WithReplay(cassette *Cassette) *OSMClient {
+	c.httpClient.Transport = replayTransport{cassette: cassette}
+	c.replay = cassette
+	return c
+}
 
-	bytes, err := io.ReadAll(response.Body)
+func (c *OSMClient) GetRelation(ctx context.Context, relationId int64) (Relation, error) {
+	res, err := c.get(ctx, c.source.relation(relationId))
 	if err != nil {
 		return Relation{}, err
 	}
 
-	if response.StatusCode != http.StatusOK {
-		return Relation{}, HttpStatusError{response.StatusCode, string(bytes)}
-	}
-
-	var relation relationsResponse
-	err = json.Unmarshal(bytes, &relation)
-	if err != nil {
-		return Relation{}, err
+	if len(res.relations) < 1 {
+		return Relation{}, HttpStatusError{StatusCode: http.StatusNotFound}
 	}
-	return relation.Elements[0], nil
+	c.invalidateChangedMembers(res.relations[0])
+	return res.relations[0], nil
 }
 
 func (c *OSMClient) GetRelationRelations(ctx context.Context, relationId int64) ([]Relation, error) {
-	url := fmt.Sprintf("%s/relation/%d/relations.json", c.baseUrl, relationId)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", c.userAgent)
+	return c.GetParentRelations(ctx, "relation", relationId)
+}
 
-	response, err := c.httpClient.Do(req)
+// GetParentRelations returns the relations which have a node, way or relation as a member
+func (c *OSMClient) GetParentRelations(ctx context.Context, elemType string, id int64) ([]Relation, error) {
+	r, err := c.source.parentRelations(elemType, id)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = response.Body.Close()
-	}()
-
-	bytes, err := io.ReadAll(response.Body)
+	res, err := c.get(ctx, r)
 	if err != nil {
 		return nil, err
 	}
-
-	if response.StatusCode != http.StatusOK {
-		return nil, HttpStatusError{response.StatusCode, string(bytes)}
+	if res.relations == nil {
+		return []Relation{}, nil
 	}
-
-	var relation relationsResponse
-	err = json.Unmarshal(bytes, &relation)
-	if err != nil {
-		return nil, err
-	}
-	return relation.Elements, nil
-
+	return res.relations, nil
 }
 
-type relationsResponse struct {
-	Elements []Relation `json:"elements"`
-}
-
-type WayCache struct {
-	mu sync.Mutex
-	v  map[int64]Way
+// get makes a request, retrying according to the client's retry policy if the API is overloaded or slow. The
+// response is decoded as JSON or XML depending on its content type
+func (c *OSMClient) get(ctx context.Context, r request) (*response, error) {
+	policy := c.getRetryPolicy()
+	var waited time.Duration
+
+	for attempt := 1; ; attempt++ {
+		res, retryAfter, err := c.getOnce(ctx, r, policy.AttemptTimeout)
+		if err == nil {
+			return res, nil
+		}
+		if attempt >= policy.MaxAttempts || !isRetryable(ctx, err) {
+			return nil, err
+		}
+
+		delay := max(policy.getDelay(attempt), retryAfter)
+		if waited+delay > policy.Budget {
+			return nil, err
+		}
+		sleepErr := sleepCtx(ctx, delay)
+		if sleepErr != nil {
+			return nil, sleepErr
+		}
+		waited += delay
+	}
 }
 
-type wayResponse struct {
-	Elements []Way `json:"elements"`
-}
+func (c *OSMClient) getOnce(ctx context.Context, r request, timeout time.Duration) (*response, time.Duration, error) {
+	release, throttled, err := c.limiter.acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+	if throttled >= throttleLogThreshold {
+		c.logger.Info("OSM request throttled", "url", r.url, "waitMs", throttled.Milliseconds())
+	}
 
-func (c *OSMClient) GetWay(ctx context.Context, wayId int64) (Way, error) {
-	cacheWay, found := c.getCachedWay(wayId)
-	if found {
-		return cacheWay, nil
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	url := fmt.Sprintf("%s/way/%d.json", c.baseUrl, wayId)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	var body io.Reader
+	if r.form != nil {
+		body = strings.NewReader(r.form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
 	if err != nil {
-		return Way{}, err
+		return nil, 0, err
 	}
 	req.Header.Set("User-Agent", c.userAgent)
+	if r.form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
 
 	response, err := c.httpClient.Do(req)
 	if err != nil {
-		return Way{}, err
+		return nil, 0, err
 	}
 	defer func() {
 		_ = response.Body.Close()
@@ -149,100 +211,140 @@ func (c *OSMClient) GetWay(ctx context.Context, wayId int64) (Way, error) {
 
 	bytes, err := io.ReadAll(response.Body)
 	if err != nil {
-		return Way{}, err
+		return nil, 0, err
 	}
 
 	if response.StatusCode != http.StatusOK {
-		return Way{}, HttpStatusError{response.StatusCode, string(bytes)}
+		retryAfter, _ := parseRetryAfter(response.Header.Get("Retry-After"), time.Now())
+		return nil, retryAfter, HttpStatusError{response.StatusCode, string(bytes)}
 	}
-
-	var wayRes wayResponse
-	err = json.Unmarshal(bytes, &wayRes)
+	res, err := decodeResponse(response.Header.Get("Content-Type"), bytes)
 	if err != nil {
-		return Way{}, err
+		return nil, 0, err
 	}
-
-	way := wayRes.Elements[0]
-	c.cacheWay(way)
-	return way, nil
+	return res, 0, nil
 }
 
-type NodeCache struct {
-	mu sync.Mutex
-	v  map[int64]Node
+func (c *OSMClient) getRetryPolicy() RetryPolicy {
+	if c.retryPolicy != nil {
+		return *c.retryPolicy
+	}
+	return c.source.retryPolicy()
 }
 
-func (c *OSMClient) GetNode(ctx context.Context, nodeId int64) (Node, error) {
-	cacheNode, found := c.getCachedNode(nodeId)
-	if found {
-		return cacheNode, nil
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		//The caller has given up, so there is no point retrying
+		return false
 	}
+	if hse, ok := errors.AsType[HttpStatusError](err); ok {
+		return isRetryableStatus(hse.StatusCode)
+	}
+	if _, ok := errors.AsType[OverpassRemarkError](err); ok {
+		//Overpass gave up on the query part-way through, e.g. because it timed out or the server is busy
+		return true
+	}
+	return isTimeout(err)
+}
 
-	url := fmt.Sprintf("%s/node/%d.json", c.baseUrl, nodeId)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return Node{}, err
+func (c *OSMClient) GetWay(ctx context.Context, wayId int64) (Way, error) {
+	cacheWay, found := c.getCachedWay(ctx, wayId)
+	if found {
+		return cacheWay, nil
 	}
-	req.Header.Set("User-Agent", c.userAgent)
 
-	response, err := c.httpClient.Do(req)
+	res, err := c.get(ctx, c.source.way(wayId))
 	if err != nil {
-		return Node{}, err
+		return Way{}, err
 	}
-	defer func() {
-		_ = response.Body.Close()
-	}()
 
-	bytes, err := io.ReadAll(response.Body)
-	if err != nil {
-		return Node{}, err
+	if len(res.ways) < 1 {
+		return Way{}, HttpStatusError{StatusCode: http.StatusNotFound}
 	}
+	way := res.ways[0]
+	c.cacheWay(way)
+	return way, nil
+}
 
-	if response.StatusCode != http.StatusOK {
-		return Node{}, HttpStatusError{response.StatusCode, string(bytes)}
+func (c *OSMClient) GetNode(ctx context.Context, nodeId int64) (Node, error) {
+	cacheNode, found := c.getCachedNode(ctx, nodeId)
+	if found {
+		return cacheNode, nil
 	}
 
-	var nodeRes nodeResponse
-	err = json.Unmarshal(bytes, &nodeRes)
+	res, err := c.get(ctx, c.source.node(nodeId))
 	if err != nil {
 		return Node{}, err
 	}
 
-	node := nodeRes.Elements[0]
+	if len(res.nodes) < 1 {
+		return Node{}, HttpStatusError{StatusCode: http.StatusNotFound}
+	}
+	node := res.nodes[0]
 	c.cacheNode(node)
 	return node, nil
 }
 
-type nodeResponse struct {
-	Elements []Node `json:"elements"`
-}
-
-func (c *OSMClient) getCachedNode(nodeId int64) (Node, bool) {
-	c.nodeCache.mu.Lock()
-	defer c.nodeCache.mu.Unlock()
-	node, found := c.nodeCache.v[nodeId]
+func (c *OSMClient) getCachedNode(ctx context.Context, nodeId int64) (Node, bool) {
+	node, found := c.cache.GetNode(nodeId)
+	if !found && c.replay != nil {
+		if res, replayed := c.replay.getCached("node", nodeId); replayed && len(res.nodes) > 0 {
+			node, found = res.nodes[0], true
+		}
+	}
+	if found {
+		node.Type = "node"
+		c.recordCached(ctx, "node", nodeId, node)
+	}
 	return node, found
 }
 
 func (c *OSMClient) cacheNode(node Node) {
-	nodeId := node.ID
-	c.nodeCache.mu.Lock()
-	defer c.nodeCache.mu.Unlock()
-	c.nodeCache.v[nodeId] = node
+	c.cache.PutNode(node)
 }
 
-func (c *OSMClient) getCachedWay(wayId int64) (Way, bool) {
-	c.wayCache.mu.Lock()
-	defer c.wayCache.mu.Unlock()
-	way, found := c.wayCache.v[wayId]
+func (c *OSMClient) getCachedWay(ctx context.Context, wayId int64) (Way, bool) {
+	way, found := c.cache.GetWay(wayId)
+	if !found && c.replay != nil {
+		if res, replayed := c.replay.getCached("way", wayId); replayed && len(res.ways) > 0 {
+			way, found = res.ways[0], true
+		}
+	}
+	if found {
+		way.Type = "way"
+		c.recordCached(ctx, "way", wayId, way)
+	}
 	return way, found
 }
 
 func (c *OSMClient) cacheWay(way Way) {
-	wayId := way.ID
-	c.wayCache.mu.Lock()
-	defer c.wayCache.mu.Unlock()
-	c.wayCache.v[wayId] = way
+	c.cache.PutWay(way)
+}
+
+// recordCached saves a node or way read from the cache in the cassette attached to the context, if recording is enabled
+func (c *OSMClient) recordCached(ctx context.Context, elemType string, id int64, element any) {
+	cassette := cassetteFromContext(ctx)
+	if !c.recording || cassette == nil {
+		return
+	}
+	err := cassette.recordCached(elemType, id, element)
+	if err != nil {
+		c.logger.Warn("failed to record cached element", "type", elemType, "id", id, "error", err.Error())
+	}
+}
+
+// invalidateChangedMembers removes the members of a relation from the cache if the relation has been edited since it
+// was last loaded, as its members are also likely to have been edited. Members can be edited without editing the
+// relation, so this only narrows the window in which cached members are stale. GetRelationFull doesn't depend on it, as
+// the members it returns replace any other cached versions
+func (c *OSMClient) invalidateChangedMembers(relation Relation) {
+	version, found := c.cache.GetRelationVersion(relation.ID)
+	if found && version != relation.Version {
+		for _, member := range relation.Members {
+			c.cache.Invalidate(member.Type, member.Ref)
+		}
+	}
+	c.cache.PutRelationVersion(relation.ID, relation.Version)
 }
 
 type HttpStatusError struct {
@@ -0,0 +1,74 @@
+package osm
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// OsmChange is the set of edits in an osmChange file, such as an OSM replication diff. Deleted elements have the
+// version which deleted them, which may be missing tags and coordinates
+type OsmChange struct {
+	Create Elements
+	Modify Elements
+	Delete Elements
+}
+
+// DecodeOsmChange reads an osmChange XML file
+func DecodeOsmChange(r io.Reader) (*OsmChange, error) {
+	change := &OsmChange{Create: newElements(), Modify: newElements(), Delete: newElements()}
+	actions := map[string]elementsSink{
+		"create": {elements: change.Create},
+		"modify": {elements: change.Modify},
+		"delete": {elements: change.Delete},
+	}
+
+	decoder := xml.NewDecoder(r)
+	var sink *elementsSink
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return change, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if action, found := actions[t.Name.Local]; found {
+				sink = &action
+				continue
+			}
+			if sink == nil {
+				continue
+			}
+			err = decodeXMLElement(decoder, t, sink)
+			if err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			if _, found := actions[t.Name.Local]; found {
+				sink = nil
+			}
+		}
+	}
+}
+
+// elementsSink adds elements to Elements whether they are visible or not, as deleted elements in an osmChange file
+// are not always marked as invisible
+type elementsSink struct {
+	elements Elements
+}
+
+func (s *elementsSink) addNode(node Node, _ bool) {
+	s.elements.Nodes[node.ID] = node
+}
+
+func (s *elementsSink) addWay(way Way, _ bool) {
+	s.elements.Ways[way.ID] = way
+}
+
+func (s *elementsSink) addRelation(relation Relation, _ bool) {
+	s.elements.Relations[relation.ID] = relation
+}
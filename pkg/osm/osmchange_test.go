@@ -0,0 +1,40 @@
+package osm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOsmChange = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6" generator="osmdbt-create-diff/0.9">
+  <create>
+    <node id="106" version="1" timestamp="2026-10-17T10:00:03Z" uid="1003" user="Mapper C" changeset="160000003" lat="55.932" lon="-3.278"/>
+  </create>
+  <modify>
+    <node id="103" version="2" timestamp="2026-10-17T10:00:01Z" uid="1001" user="Mapper A" changeset="160000001" lat="55.9226" lon="-3.2867"/>
+    <way id="203" version="2" timestamp="2026-10-17T10:00:03Z" uid="1003" user="Mapper C" changeset="160000003">
+      <nd ref="104"/>
+      <nd ref="106"/>
+    </way>
+  </modify>
+  <delete>
+    <node id="998" version="3" timestamp="2026-10-17T10:00:04Z" uid="1002" user="Mapper B" changeset="160000004" visible="false"/>
+    <relation id="301" version="5" timestamp="2026-10-17T10:00:04Z" uid="1002" user="Mapper B" changeset="160000004"/>
+  </delete>
+</osmChange>`
+
+func TestDecodeOsmChange(t *testing.T) {
+	change, err := DecodeOsmChange(strings.NewReader(testOsmChange))
+	require.NoError(t, err)
+
+	assert.Len(t, change.Create.Nodes, 1)
+	assert.Equal(t, "Mapper C", change.Create.Nodes[106].User)
+	assert.Len(t, change.Modify.Nodes, 1)
+	assert.Equal(t, []int64{104, 106}, change.Modify.Ways[203].Nodes)
+	assert.Equal(t, int64(160000004), change.Delete.Nodes[998].Changeset)
+	assert.Contains(t, change.Delete.Relations, int64(301))
+	assert.Empty(t, change.Delete.Ways)
+}
@@ -0,0 +1,544 @@
+package osm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxPBFBlobSize is the largest blob allowed by the PBF format
+const maxPBFBlobSize = 32 * 1024 * 1024
+
+// pbfFeatures are the required features of a PBF file which DecodePBF can read
+var pbfFeatures = map[string]bool{"OsmSchema-V0.6": true, "DenseNodes": true}
+
+// DecodePBF reads the elements from an OSM PBF extract, e.g. as published by Geofabrik. See
+// https://wiki.openstreetmap.org/wiki/PBF_Format for details of the format
+func DecodePBF(r io.Reader) (*Store, error) {
+	store := newStore()
+	for {
+		blobType, data, err := readPBFBlob(r)
+		if errors.Is(err, io.EOF) {
+			return store, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch blobType {
+		case "OSMHeader":
+			err = checkPBFHeader(data)
+		case "OSMData":
+			err = decodePBFBlock(store, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readPBFBlob reads the next blob from the file and returns its type and uncompressed data
+func readPBFBlob(r io.Reader) (string, []byte, error) {
+	var headerSize uint32
+	err := binary.Read(r, binary.BigEndian, &headerSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if headerSize > maxPBFBlobSize {
+		return "", nil, fmt.Errorf("PBF blob header too large: %d bytes", headerSize)
+	}
+	header := make([]byte, headerSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var blobType string
+	var blobSize int64
+	err = readPBFFields(header, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		switch num {
+		case 1:
+			blobType = string(b)
+		case 3:
+			blobSize = int64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if blobSize < 0 || blobSize > maxPBFBlobSize {
+		return "", nil, fmt.Errorf("PBF blob too large: %d bytes", blobSize)
+	}
+	blob := make([]byte, blobSize)
+	_, err = io.ReadFull(r, blob)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var data []byte
+	compressed := false
+	err = readPBFFields(blob, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		switch num {
+		case 1:
+			data = b
+		case 3:
+			zr, err := zlib.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return err
+			}
+			data, err = io.ReadAll(io.LimitReader(zr, maxPBFBlobSize))
+			if err != nil {
+				return err
+			}
+		case 4, 5, 6, 7:
+			compressed = true
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if data == nil && compressed {
+		return "", nil, errors.New("unsupported PBF blob compression, only zlib is supported")
+	}
+	return blobType, data, nil
+}
+
+func checkPBFHeader(data []byte) error {
+	return readPBFFields(data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		if num == 4 && !pbfFeatures[string(b)] {
+			return fmt.Errorf("unsupported PBF feature %q", string(b))
+		}
+		return nil
+	})
+}
+
+// pbfBlock holds the fields of a PrimitiveBlock needed to decode its elements
+type pbfBlock struct {
+	strings         []string
+	granularity     int64
+	dateGranularity int64
+	latOffset       int64
+	lonOffset       int64
+}
+
+func (b pbfBlock) coord(offset int64, value int64) float64 {
+	return float64(offset+b.granularity*value) / 1e9
+}
+
+func (b pbfBlock) timestamp(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(value * b.dateGranularity).UTC()
+}
+
+func (b pbfBlock) tags(keys []uint64, vals []uint64) map[string]string {
+	if len(keys) < 1 {
+		return nil
+	}
+	tags := map[string]string{}
+	for i, key := range keys {
+		if i < len(vals) {
+			tags[b.strings[key]] = b.strings[vals[i]]
+		}
+	}
+	return tags
+}
+
+func decodePBFBlock(store *Store, data []byte) error {
+	block := pbfBlock{granularity: 100, dateGranularity: 1000}
+	groups := [][]byte{}
+	err := readPBFFields(data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		switch num {
+		case 1:
+			return readPBFFields(b, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+				if num == 1 {
+					block.strings = append(block.strings, string(b))
+				}
+				return nil
+			})
+		case 2:
+			groups = append(groups, b)
+		case 17:
+			block.granularity = int64(v)
+		case 18:
+			block.dateGranularity = int64(v)
+		case 19:
+			block.latOffset = int64(v)
+		case 20:
+			block.lonOffset = int64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	//The string table may come after the groups, so groups are decoded once the whole block has been read
+	for _, group := range groups {
+		err = readPBFFields(group, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+			switch num {
+			case 1:
+				return decodePBFNode(store, block, b)
+			case 2:
+				return decodePBFDenseNodes(store, block, b)
+			case 3:
+				return decodePBFWay(store, block, b)
+			case 4:
+				return decodePBFRelation(store, block, b)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodePBFNode(store *Store, block pbfBlock, data []byte) error {
+	node := Node{Type: "node"}
+	var keys, vals []uint64
+	var lat, lon int64
+	info := pbfInfo{visible: true}
+	err := readPBFFields(data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		var err error
+		switch num {
+		case 1:
+			node.ID = protowire.DecodeZigZag(v)
+		case 2:
+			keys, err = appendPBFVarints(keys, typ, b, v)
+		case 3:
+			vals, err = appendPBFVarints(vals, typ, b, v)
+		case 4:
+			info, err = decodePBFInfo(b)
+		case 8:
+			lat = protowire.DecodeZigZag(v)
+		case 9:
+			lon = protowire.DecodeZigZag(v)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err = block.checkStrings(keys, vals, info.userSids()); err != nil {
+		return err
+	}
+	node.Lat = block.coord(block.latOffset, lat)
+	node.Lon = block.coord(block.lonOffset, lon)
+	node.Tags = block.tags(keys, vals)
+	node.Version = info.version
+	node.Timestamp = block.timestamp(info.timestamp)
+	node.Changeset = info.changeset
+	node.UID = info.uid
+	node.User = block.user(info.userSid)
+	store.addNode(node, info.visible)
+	return nil
+}
+
+func decodePBFDenseNodes(store *Store, block pbfBlock, data []byte) error {
+	var ids, lats, lons, keysVals, versions, timestamps, changesets, uids, userSids, visibles []uint64
+	err := readPBFFields(data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		var err error
+		switch num {
+		case 1:
+			ids, err = appendPBFVarints(ids, typ, b, v)
+		case 5:
+			err = readPBFFields(b, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+				var err error
+				switch num {
+				case 1:
+					versions, err = appendPBFVarints(versions, typ, b, v)
+				case 2:
+					timestamps, err = appendPBFVarints(timestamps, typ, b, v)
+				case 3:
+					changesets, err = appendPBFVarints(changesets, typ, b, v)
+				case 4:
+					uids, err = appendPBFVarints(uids, typ, b, v)
+				case 5:
+					userSids, err = appendPBFVarints(userSids, typ, b, v)
+				case 6:
+					visibles, err = appendPBFVarints(visibles, typ, b, v)
+				}
+				return err
+			})
+		case 8:
+			lats, err = appendPBFVarints(lats, typ, b, v)
+		case 9:
+			lons, err = appendPBFVarints(lons, typ, b, v)
+		case 10:
+			keysVals, err = appendPBFVarints(keysVals, typ, b, v)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return errors.New("invalid PBF dense nodes: mismatched number of IDs and coordinates")
+	}
+
+	//IDs, coordinates and metadata are delta-encoded, and the tags of each node are terminated by a zero
+	var id, lat, lon, timestamp, changeset, uid, userSid int64
+	kv := 0
+	for i := range ids {
+		id += protowire.DecodeZigZag(ids[i])
+		lat += protowire.DecodeZigZag(lats[i])
+		lon += protowire.DecodeZigZag(lons[i])
+		node := Node{Type: "node", ID: id, Lat: block.coord(block.latOffset, lat), Lon: block.coord(block.lonOffset, lon)}
+		if i < len(versions) {
+			node.Version = int32(versions[i])
+		}
+		if i < len(timestamps) {
+			timestamp += protowire.DecodeZigZag(timestamps[i])
+			node.Timestamp = block.timestamp(timestamp)
+		}
+		if i < len(changesets) {
+			changeset += protowire.DecodeZigZag(changesets[i])
+			node.Changeset = changeset
+		}
+		if i < len(uids) {
+			uid += protowire.DecodeZigZag(uids[i])
+			node.UID = uid
+		}
+		if i < len(userSids) {
+			userSid += protowire.DecodeZigZag(userSids[i])
+			if userSid < 0 || userSid >= int64(len(block.strings)) {
+				return fmt.Errorf("invalid PBF string table index %d", userSid)
+			}
+			node.User = block.user(uint64(userSid))
+		}
+
+		var keys, vals []uint64
+		for kv < len(keysVals) && keysVals[kv] != 0 {
+			if kv+1 >= len(keysVals) {
+				return errors.New("invalid PBF dense nodes: key without value")
+			}
+			keys = append(keys, keysVals[kv])
+			vals = append(vals, keysVals[kv+1])
+			kv += 2
+		}
+		kv++
+		if err = block.checkStrings(keys, vals); err != nil {
+			return err
+		}
+		node.Tags = block.tags(keys, vals)
+		store.addNode(node, i >= len(visibles) || visibles[i] != 0)
+	}
+	return nil
+}
+
+func decodePBFWay(store *Store, block pbfBlock, data []byte) error {
+	way := Way{Type: "way", Nodes: []int64{}}
+	var keys, vals, refs []uint64
+	info := pbfInfo{visible: true}
+	err := readPBFFields(data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		var err error
+		switch num {
+		case 1:
+			way.ID = int64(v)
+		case 2:
+			keys, err = appendPBFVarints(keys, typ, b, v)
+		case 3:
+			vals, err = appendPBFVarints(vals, typ, b, v)
+		case 4:
+			info, err = decodePBFInfo(b)
+		case 8:
+			refs, err = appendPBFVarints(refs, typ, b, v)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err = block.checkStrings(keys, vals, info.userSids()); err != nil {
+		return err
+	}
+
+	var ref int64
+	for _, delta := range refs {
+		ref += protowire.DecodeZigZag(delta)
+		way.Nodes = append(way.Nodes, ref)
+	}
+	way.Tags = block.tags(keys, vals)
+	way.Version = info.version
+	way.Timestamp = block.timestamp(info.timestamp)
+	way.Changeset = info.changeset
+	way.UID = info.uid
+	way.User = block.user(info.userSid)
+	store.addWay(way, info.visible)
+	return nil
+}
+
+var pbfMemberTypes = []string{"node", "way", "relation"}
+
+func decodePBFRelation(store *Store, block pbfBlock, data []byte) error {
+	relation := Relation{Type: "relation", Members: []Member{}}
+	var keys, vals, roles, memIds, types []uint64
+	info := pbfInfo{visible: true}
+	err := readPBFFields(data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		var err error
+		switch num {
+		case 1:
+			relation.ID = int64(v)
+		case 2:
+			keys, err = appendPBFVarints(keys, typ, b, v)
+		case 3:
+			vals, err = appendPBFVarints(vals, typ, b, v)
+		case 4:
+			info, err = decodePBFInfo(b)
+		case 8:
+			roles, err = appendPBFVarints(roles, typ, b, v)
+		case 9:
+			memIds, err = appendPBFVarints(memIds, typ, b, v)
+		case 10:
+			types, err = appendPBFVarints(types, typ, b, v)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(roles) != len(memIds) || len(types) != len(memIds) {
+		return errors.New("invalid PBF relation: mismatched number of members, roles and types")
+	}
+	if err = block.checkStrings(keys, vals, roles, info.userSids()); err != nil {
+		return err
+	}
+
+	var ref int64
+	for i, delta := range memIds {
+		ref += protowire.DecodeZigZag(delta)
+		if types[i] >= uint64(len(pbfMemberTypes)) {
+			return fmt.Errorf("invalid PBF relation: unknown member type %d", types[i])
+		}
+		relation.Members = append(relation.Members, Member{Type: pbfMemberTypes[types[i]], Ref: ref, Role: block.strings[roles[i]]})
+	}
+	relation.Tags = block.tags(keys, vals)
+	relation.Version = info.version
+	relation.Timestamp = block.timestamp(info.timestamp)
+	relation.Changeset = info.changeset
+	relation.UID = info.uid
+	relation.User = block.user(info.userSid)
+	store.addRelation(relation, info.visible)
+	return nil
+}
+
+// pbfInfo is the metadata of an element. Elements are visible unless the file has history and the element has been
+// deleted
+type pbfInfo struct {
+	version   int32
+	timestamp int64
+	changeset int64
+	uid       int64
+	userSid   uint64
+	visible   bool
+}
+
+// userSids returns the string table index of the user's name, if the element has one
+func (i pbfInfo) userSids() []uint64 {
+	if i.userSid == 0 {
+		return nil
+	}
+	return []uint64{i.userSid}
+}
+
+func decodePBFInfo(data []byte) (pbfInfo, error) {
+	info := pbfInfo{visible: true}
+	err := readPBFFields(data, func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error {
+		switch num {
+		case 1:
+			info.version = int32(v)
+		case 2:
+			info.timestamp = int64(v)
+		case 3:
+			info.changeset = int64(v)
+		case 4:
+			info.uid = int64(int32(v))
+		case 5:
+			info.userSid = v
+		case 6:
+			info.visible = v != 0
+		}
+		return nil
+	})
+	return info, err
+}
+
+// user returns the user name at a string table index. Index 0 is always the empty string
+func (b pbfBlock) user(sid uint64) string {
+	if sid == 0 {
+		return ""
+	}
+	return b.strings[sid]
+}
+
+// checkStrings makes sure all the string table indexes are valid
+func (b pbfBlock) checkStrings(indexLists ...[]uint64) error {
+	for _, indexes := range indexLists {
+		for _, idx := range indexes {
+			if idx >= uint64(len(b.strings)) {
+				return fmt.Errorf("invalid PBF string table index %d", idx)
+			}
+		}
+	}
+	return nil
+}
+
+// readPBFFields calls fn for each field in a protobuf message, with either the bytes of a length-delimited field or
+// the value of a varint field
+func readPBFFields(data []byte, fn func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var b []byte
+		var v uint64
+		switch typ {
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		err := fn(num, typ, b, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendPBFVarints appends the values of a repeated varint field, which may be packed or not
+func appendPBFVarints(values []uint64, typ protowire.Type, b []byte, v uint64) ([]uint64, error) {
+	if typ == protowire.VarintType {
+		return append(values, v), nil
+	}
+	for len(b) > 0 {
+		value, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		values = append(values, value)
+		b = b[n:]
+	}
+	return values, nil
+}
@@ -0,0 +1,20 @@
+package osm
+
+import "context"
+
+// Provider loads the elements needed to validate relations. OSMClient loads them from the OSM API or Overpass, and
+// Store holds them in memory, e.g. after reading an extract with LoadFile
+type Provider interface {
+	GetRelation(ctx context.Context, relationId int64) (Relation, error)
+	GetRelationFull(ctx context.Context, relationId int64) (FullRelation, error)
+	// GetRelationRelations returns the relations which have the relation as a member, e.g. its route_master
+	GetRelationRelations(ctx context.Context, relationId int64) ([]Relation, error)
+	// GetParentRelations returns the relations which have a node, way or relation as a member, e.g. a stop's stop_area
+	GetParentRelations(ctx context.Context, elemType string, id int64) ([]Relation, error)
+	LoadWays(ctx context.Context, wayIds []int64) (map[int64]*Way, map[int64]error)
+	LoadNodes(ctx context.Context, nodeIds []int64) (map[int64]*Node, map[int64]error)
+}
+
+var _ Provider = (*OSMClient)(nil)
+var _ Provider = (*Store)(nil)
+var _ Provider = (*HistoricalProvider)(nil)
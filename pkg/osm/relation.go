@@ -1,15 +1,21 @@
 package osm
 
-import "slices"
-
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"time"
+)
 
 type Relation struct {
-	Type    string            `json:"type"`
-	ID      int64             `json:"id"`
-	Version int32             `json:"version"`
-	Members []Member          `json:"members"`
-	Tags    map[string]string `json:"tags"`
+	Type      string            `json:"type"`
+	ID        int64             `json:"id"`
+	Version   int32             `json:"version"`
+	Timestamp time.Time         `json:"timestamp,omitzero"`
+	Changeset int64             `json:"changeset,omitempty"`
+	User      string            `json:"user,omitempty"`
+	UID       int64             `json:"uid,omitempty"`
+	Members   []Member          `json:"members"`
+	Tags      map[string]string `json:"tags"`
 }
 
 func (r Relation) GetTags() map[string]string {
@@ -0,0 +1,69 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+)
+
+// FullRelation is a relation along with its member ways and nodes, including the nodes of the member ways
+type FullRelation struct {
+	Relation Relation
+	Ways     map[int64]Way
+	Nodes    map[int64]Node
+	// Relations is only loaded where other relations are validated with the relation, e.g. route master variants, or the
+	// stop areas of a route's stops and platforms
+	Relations map[int64]Relation
+}
+
+// Elements is a set of nodes, ways and relations of mixed types, e.g. everything within a bounding box
+type Elements struct {
+	Relations map[int64]Relation
+	Ways      map[int64]Way
+	Nodes     map[int64]Node
+}
+
+func newElements() Elements {
+	return Elements{Relations: map[int64]Relation{}, Ways: map[int64]Way{}, Nodes: map[int64]Node{}}
+}
+
+// GetRelationFull loads a relation and all of its members with a single request. The member ways and nodes are added
+// to the client caches, so validating the relation afterwards does not need any further requests
+func (c *OSMClient) GetRelationFull(ctx context.Context, relationId int64) (FullRelation, error) {
+	res, err := c.get(ctx, c.source.relationFull(relationId))
+	if err != nil {
+		return FullRelation{}, err
+	}
+
+	elements := res.elements()
+	relation, found := elements.Relations[relationId]
+	if !found {
+		//Overpass returns no elements for a relation which doesn't exist
+		return FullRelation{}, HttpStatusError{StatusCode: http.StatusNotFound}
+	}
+
+	c.invalidateChangedMembers(relation)
+	c.cacheElements(elements)
+	return FullRelation{Relation: relation, Ways: elements.Ways, Nodes: elements.Nodes}, nil
+}
+
+// GetBBox loads every element within a bounding box with a single request, and adds the ways and nodes to the client
+// caches. The OSM API limits the size of the bounding box, so Overpass should be used for larger areas
+func (c *OSMClient) GetBBox(ctx context.Context, bbox BBox) (Elements, error) {
+	res, err := c.get(ctx, c.source.bbox(bbox))
+	if err != nil {
+		return Elements{}, err
+	}
+
+	elements := res.elements()
+	c.cacheElements(elements)
+	return elements, nil
+}
+
+func (c *OSMClient) cacheElements(elements Elements) {
+	for _, way := range elements.Ways {
+		c.cacheWay(way)
+	}
+	for _, node := range elements.Nodes {
+		c.cacheNode(node)
+	}
+}
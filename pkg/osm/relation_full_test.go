@@ -0,0 +1,81 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getRelationFull(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/relation_full.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name      string
+		handlerFn func(t *testing.T) func(w http.ResponseWriter, r *http.Request)
+		checkFn   func(t *testing.T, client *OSMClient, r FullRelation, err error)
+	}{
+		{
+			name: "HTTP 200",
+			handlerFn: func(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					require.Equal(t, "/relation/301/full.json", r.RequestURI)
+					_, err := w.Write(bytes)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			checkFn: func(t *testing.T, client *OSMClient, r FullRelation, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int64(301), r.Relation.ID)
+				assert.Len(t, r.Relation.Members, 3)
+				assert.Len(t, r.Ways, 2)
+				assert.Len(t, r.Nodes, 3)
+				assert.Equal(t, []int64{102, 103}, r.Ways[202].Nodes)
+
+				//Members should now be served from the cache
+				ways, _ := client.LoadWays(context.Background(), []int64{201, 202})
+				assert.NotNil(t, ways[201])
+				assert.NotNil(t, ways[202])
+				nodes, _ := client.LoadNodes(context.Background(), []int64{101})
+				assert.NotNil(t, nodes[101])
+			},
+		},
+		{
+			name: "HTTP 410",
+			handlerFn: func(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusGone)
+				}
+			},
+			checkFn: func(t *testing.T, client *OSMClient, r FullRelation, err error) {
+				require.EqualError(t, err, "HTTP status code 410")
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			handlerFn := tc.handlerFn(t)
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				require.Equal(t, 1, requests, "unexpected request %s", r.RequestURI)
+				handlerFn(w, r)
+			}))
+			defer svr.Close()
+
+			client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
+			relation, err := client.GetRelationFull(context.Background(), 301)
+			tc.checkFn(t, client, relation, err)
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package osm
 
 import (
-	"encoding/json"
 	"os"
 	"testing"
 
@@ -14,13 +13,12 @@ func Test_unmarshallRelation(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	var relation relationsResponse
-	err = json.Unmarshal(bytes, &relation)
+	res, err := decodeResponse("application/json", bytes)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, int64(3411082864), relation.Elements[0].ID)
+	assert.Equal(t, int64(3411082864), res.relations[0].ID)
 }
 
 func Test_isPTv2(t *testing.T) {
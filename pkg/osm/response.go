@@ -0,0 +1,102 @@
+package osm
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+)
+
+// elementSink receives the elements read by a decoder. Elements which have been deleted are not visible
+type elementSink interface {
+	addNode(node Node, visible bool)
+	addWay(way Way, visible bool)
+	addRelation(relation Relation, visible bool)
+}
+
+// response holds the elements returned by the API in the order they were returned, whether the response was JSON or
+// XML. Deleted elements, which the multi-fetch and history endpoints return with visible=false, are kept separately
+type response struct {
+	nodes            []Node
+	ways             []Way
+	relations        []Relation
+	deletedNodes     []Node
+	deletedWays      []Way
+	deletedRelations []Relation
+	hidden           map[elementKey]bool
+}
+
+func decodeResponse(contentType string, data []byte) (*response, error) {
+	res := &response{hidden: map[elementKey]bool{}}
+	var err error
+	if isXMLContentType(contentType) {
+		err = readXML(bytes.NewReader(data), res)
+	} else {
+		err = readJSON(data, res)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// isXMLContentType returns true for OSM XML, which the API returns as application/xml and Overpass as
+// application/osm3s+xml
+func isXMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+func (r *response) addNode(node Node, visible bool) {
+	if !visible {
+		r.hidden[elementKey{"node", node.ID}] = true
+		r.deletedNodes = append(r.deletedNodes, node)
+		return
+	}
+	r.nodes = append(r.nodes, node)
+}
+
+func (r *response) addWay(way Way, visible bool) {
+	if !visible {
+		r.hidden[elementKey{"way", way.ID}] = true
+		r.deletedWays = append(r.deletedWays, way)
+		return
+	}
+	r.ways = append(r.ways, way)
+}
+
+func (r *response) addRelation(relation Relation, visible bool) {
+	if !visible {
+		r.hidden[elementKey{"relation", relation.ID}] = true
+		r.deletedRelations = append(r.deletedRelations, relation)
+		return
+	}
+	r.relations = append(r.relations, relation)
+}
+
+// hiddenIds returns the IDs of the deleted elements of a type
+func (r *response) hiddenIds(elemType string) map[int64]bool {
+	ids := map[int64]bool{}
+	for key := range r.hidden {
+		if key.elemType == elemType {
+			ids[key.id] = true
+		}
+	}
+	return ids
+}
+
+func (r *response) elements() Elements {
+	elements := newElements()
+	for _, relation := range r.relations {
+		elements.Relations[relation.ID] = relation
+	}
+	for _, way := range r.ways {
+		elements.Ways[way.ID] = way
+	}
+	for _, node := range r.nodes {
+		elements.Nodes[node.ID] = node
+	}
+	return elements
+}
@@ -0,0 +1,112 @@
+package osm
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests are retried when the API is overloaded or slow to respond
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of requests made, including the first one
+	MaxAttempts int
+	// AttemptTimeout is the time allowed for each individual request
+	AttemptTimeout time.Duration
+	// BaseDelay is the delay before the first retry, doubled for each subsequent retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts
+	MaxDelay time.Duration
+	// Budget is the maximum total time spent waiting between attempts
+	Budget time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		AttemptTimeout: 3 * time.Second,
+		BaseDelay:      500 * time.Millisecond,
+		MaxDelay:       5 * time.Second,
+		Budget:         10 * time.Second,
+	}
+}
+
+// OverpassRetryPolicy allows each request long enough for Overpass to run the query, as Overpass only gives up on a
+// query once its own timeout has passed
+func OverpassRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		AttemptTimeout: overpassQueryTimeout + 5*time.Second,
+		BaseDelay:      time.Second,
+		MaxDelay:       10 * time.Second,
+		Budget:         20 * time.Second,
+	}
+}
+
+// NoRetryPolicy makes a single attempt for each request
+func NoRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1, AttemptTimeout: 3 * time.Second}
+}
+
+// getDelay returns the delay before the given retry (starting at 1) using exponential backoff with jitter
+func (p RetryPolicy) getDelay(retry int) time.Duration {
+	delay := p.BaseDelay << (retry - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	//Use a random delay between half and all of the backoff so that parallel requests don't retry in lockstep
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if netErr, ok := errors.AsType[net.Error](err); ok {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(header)
+	if err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	date, err := http.ParseTime(header)
+	if err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,208 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_retry(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/way.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPolicy := RetryPolicy{
+		MaxAttempts:    3,
+		AttemptTimeout: 50 * time.Millisecond,
+		BaseDelay:      time.Millisecond,
+		MaxDelay:       5 * time.Millisecond,
+		Budget:         time.Second,
+	}
+
+	testcases := []struct {
+		name        string
+		policy      RetryPolicy
+		handlerFn   func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request)
+		expAttempts int32
+		checkFn     func(t *testing.T, w Way, err error)
+	}{
+		{
+			name:   "should retry HTTP 503",
+			policy: testPolicy,
+			handlerFn: func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if attempt == 1 {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					_, err := w.Write(bytes)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			expAttempts: 2,
+			checkFn: func(t *testing.T, w Way, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int64(2154620362), w.ID)
+			},
+		},
+		{
+			name:   "should retry HTTP 429 with Retry-After header",
+			policy: testPolicy,
+			handlerFn: func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if attempt < 3 {
+						w.Header().Set("Retry-After", "0")
+						w.WriteHeader(http.StatusTooManyRequests)
+						return
+					}
+					_, err := w.Write(bytes)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			expAttempts: 3,
+			checkFn: func(t *testing.T, w Way, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:   "should retry timeout",
+			policy: testPolicy,
+			handlerFn: func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if attempt == 1 {
+						time.Sleep(200 * time.Millisecond)
+					}
+					_, err := w.Write(bytes)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			expAttempts: 2,
+			checkFn: func(t *testing.T, w Way, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:   "should return error after max attempts",
+			policy: testPolicy,
+			handlerFn: func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusGatewayTimeout)
+				}
+			},
+			expAttempts: 3,
+			checkFn: func(t *testing.T, w Way, err error) {
+				require.EqualError(t, err, "HTTP status code 504")
+			},
+		},
+		{
+			name:   "should not retry if Retry-After exceeds budget",
+			policy: testPolicy,
+			handlerFn: func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Retry-After", "60")
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			},
+			expAttempts: 1,
+			checkFn: func(t *testing.T, w Way, err error) {
+				require.EqualError(t, err, "HTTP status code 429")
+			},
+		},
+		{
+			name:   "should not retry HTTP 404",
+			policy: testPolicy,
+			handlerFn: func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			expAttempts: 1,
+			checkFn: func(t *testing.T, w Way, err error) {
+				require.EqualError(t, err, "HTTP status code 404")
+			},
+		},
+		{
+			name:   "should not retry with NoRetryPolicy",
+			policy: NoRetryPolicy(),
+			handlerFn: func(t *testing.T, attempt int32) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			},
+			expAttempts: 1,
+			checkFn: func(t *testing.T, w Way, err error) {
+				require.EqualError(t, err, "HTTP status code 503")
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.handlerFn(t, attempts.Add(1))(w, r)
+			}))
+			defer svr.Close()
+
+			client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL).WithRetryPolicy(tc.policy)
+			way, err := client.GetWay(context.Background(), 2154620362)
+			tc.checkFn(t, way, err)
+			assert.Equal(t, tc.expAttempts, attempts.Load())
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name     string
+		header   string
+		expected time.Duration
+		expOk    bool
+	}{
+		{name: "empty header", header: "", expected: 0, expOk: false},
+		{name: "seconds", header: "120", expected: 2 * time.Minute, expOk: true},
+		{name: "HTTP date", header: "Tue, 01 Sep 2026 12:00:30 GMT", expected: 30 * time.Second, expOk: true},
+		{name: "HTTP date in the past", header: "Tue, 01 Sep 2026 11:00:00 GMT", expected: 0, expOk: true},
+		{name: "invalid value", header: "soon", expected: 0, expOk: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, ok := parseRetryAfter(tc.header, now)
+			assert.Equal(t, tc.expOk, ok)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func Test_getDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry, expMax := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		5:  time.Second,
+		60: time.Second,
+	} {
+		d := policy.getDelay(retry)
+		assert.GreaterOrEqual(t, d, expMax/2)
+		assert.LessOrEqual(t, d, expMax)
+	}
+}
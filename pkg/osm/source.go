@@ -0,0 +1,207 @@
+package osm
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultOverpassUrl = "https://overpass-api.de/api/interpreter"
+
+// overpassQueryTimeout is the time Overpass is allowed to spend on each query before it gives up
+const overpassQueryTimeout = 25 * time.Second
+
+// source builds the requests used to load elements, so that they can be loaded from either the OSM API or an Overpass
+// API instance. Both return elements in the same JSON or XML formats
+type source interface {
+	relation(relationId int64) request
+	parentRelations(elemType string, id int64) (request, error)
+	relationFull(relationId int64) request
+	way(wayId int64) request
+	ways(wayIds []int64) request
+	node(nodeId int64) request
+	nodes(nodeIds []int64) request
+	bbox(bbox BBox) request
+	// retryPolicy is the default retry policy for requests to the source
+	retryPolicy() RetryPolicy
+	// withXML returns a copy of the source which requests XML instead of JSON
+	withXML() source
+}
+
+// historySource builds requests for old versions of elements. Only the OSM API supports these
+type historySource interface {
+	history(elemType string, id int64) request
+	version(elemType string, id int64, version int32) request
+}
+
+type request struct {
+	method string
+	url    string
+	form   url.Values
+}
+
+// BBox is a bounding box in WGS84 coordinates
+type BBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+type apiSource struct {
+	baseUrl string
+	xml     bool
+}
+
+func (s apiSource) relation(relationId int64) request {
+	return s.get(fmt.Sprintf("/relation/%d%s", relationId, s.ext()))
+}
+
+func (s apiSource) parentRelations(elemType string, id int64) (request, error) {
+	if elemType != "node" && elemType != "way" && elemType != "relation" {
+		return request{}, unsupportedTypeError(elemType)
+	}
+	return s.get(fmt.Sprintf("/%s/%d/relations%s", elemType, id, s.ext())), nil
+}
+
+func (s apiSource) relationFull(relationId int64) request {
+	return s.get(fmt.Sprintf("/relation/%d/full%s", relationId, s.ext()))
+}
+
+func (s apiSource) way(wayId int64) request {
+	return s.get(fmt.Sprintf("/way/%d%s", wayId, s.ext()))
+}
+
+func (s apiSource) ways(wayIds []int64) request {
+	return s.get(fmt.Sprintf("/ways%s?ways=%s", s.ext(), joinIds(wayIds)))
+}
+
+func (s apiSource) node(nodeId int64) request {
+	return s.get(fmt.Sprintf("/node/%d%s", nodeId, s.ext()))
+}
+
+func (s apiSource) nodes(nodeIds []int64) request {
+	return s.get(fmt.Sprintf("/nodes%s?nodes=%s", s.ext(), joinIds(nodeIds)))
+}
+
+func (s apiSource) bbox(bbox BBox) request {
+	return s.get(fmt.Sprintf("/map%s?bbox=%f,%f,%f,%f", s.ext(), bbox.MinLon, bbox.MinLat, bbox.MaxLon, bbox.MaxLat))
+}
+
+func (s apiSource) history(elemType string, id int64) request {
+	return s.get(fmt.Sprintf("/%s/%d/history%s", elemType, id, s.ext()))
+}
+
+func (s apiSource) version(elemType string, id int64, version int32) request {
+	return s.get(fmt.Sprintf("/%s/%d/%d%s", elemType, id, version, s.ext()))
+}
+
+func (s apiSource) retryPolicy() RetryPolicy {
+	return DefaultRetryPolicy()
+}
+
+func (s apiSource) withXML() source {
+	s.xml = true
+	return s
+}
+
+// ext returns the suffix which selects the response format. The API returns XML if there is no suffix
+func (s apiSource) ext() string {
+	if s.xml {
+		return ""
+	}
+	return ".json"
+}
+
+func (s apiSource) get(path string) request {
+	return request{method: http.MethodGet, url: s.baseUrl + path}
+}
+
+// overpassSource queries an Overpass API instance. Overpass doesn't return an error for missing elements, so they are
+// reported as not found by the client instead. If a query fails part-way through, Overpass still answers HTTP 200 but
+// adds a remark, which is returned as an OverpassRemarkError so that missing elements aren't reported as deleted
+type overpassSource struct {
+	url string
+	xml bool
+}
+
+func (s overpassSource) relation(relationId int64) request {
+	return s.query(fmt.Sprintf("rel(%d);out meta;", relationId))
+}
+
+// parentStatements are the Overpass queries for the parent relations of each element type. Overpass abbreviates the
+// element types, e.g. rel(bn) finds the relations of the nodes in the input set
+var parentStatements = map[string]string{
+	"node":     "node(%d);rel(bn);out meta;",
+	"way":      "way(%d);rel(bw);out meta;",
+	"relation": "rel(%d);rel(br);out meta;",
+}
+
+func (s overpassSource) parentRelations(elemType string, id int64) (request, error) {
+	statement, found := parentStatements[elemType]
+	if !found {
+		return request{}, unsupportedTypeError(elemType)
+	}
+	return s.query(fmt.Sprintf(statement, id)), nil
+}
+
+func unsupportedTypeError(elemType string) error {
+	return fmt.Errorf("unsupported element type '%s'", elemType)
+}
+
+func (s overpassSource) relationFull(relationId int64) request {
+	//Recurse down to the member ways and nodes, and the nodes of the member ways
+	return s.query(fmt.Sprintf("rel(%d);(._;>;);out meta;", relationId))
+}
+
+func (s overpassSource) way(wayId int64) request {
+	return s.ways([]int64{wayId})
+}
+
+func (s overpassSource) ways(wayIds []int64) request {
+	return s.query(fmt.Sprintf("way(id:%s);out meta;", joinIds(wayIds)))
+}
+
+func (s overpassSource) node(nodeId int64) request {
+	return s.nodes([]int64{nodeId})
+}
+
+func (s overpassSource) nodes(nodeIds []int64) request {
+	return s.query(fmt.Sprintf("node(id:%s);out meta;", joinIds(nodeIds)))
+}
+
+func (s overpassSource) bbox(bbox BBox) request {
+	b := fmt.Sprintf("%f,%f,%f,%f", bbox.MinLat, bbox.MinLon, bbox.MaxLat, bbox.MaxLon)
+	return s.query(fmt.Sprintf("(nwr(%s););(._;>;);out meta;", b))
+}
+
+func (s overpassSource) retryPolicy() RetryPolicy {
+	return OverpassRetryPolicy()
+}
+
+func (s overpassSource) withXML() source {
+	s.xml = true
+	return s
+}
+
+func (s overpassSource) query(query string) request {
+	format := "json"
+	if s.xml {
+		format = "xml"
+	}
+	settings := fmt.Sprintf("[out:%s][timeout:%d];", format, int(overpassQueryTimeout.Seconds()))
+	query = strings.Join([]string{settings, query}, "")
+	return request{method: http.MethodPost, url: s.url, form: url.Values{"data": {query}}}
+}
+
+// OverpassRemarkError is a remark in an Overpass response, e.g. "runtime error: Query timed out", which means the
+// response may be missing elements
+type OverpassRemarkError struct {
+	Remark string
+}
+
+func (e OverpassRemarkError) Error() string {
+	return fmt.Sprintf("overpass remark: %s", e.Remark)
+}
@@ -0,0 +1,213 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_overpass(t *testing.T) {
+	waysBytes, err := os.ReadFile("testdata/overpass_ways.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullBytes, err := os.ReadFile("testdata/relation_full.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name     string
+		expQuery string
+		response []byte
+		testFn   func(t *testing.T, client *OSMClient)
+	}{
+		{
+			name:     "should load ways and report missing ways",
+			expQuery: "[out:json][timeout:25];way(id:201,202);out meta;",
+			response: waysBytes,
+			testFn: func(t *testing.T, client *OSMClient) {
+				ways, errs := client.LoadWays(context.Background(), []int64{201, 202})
+				require.NotNil(t, ways[201])
+				assert.Equal(t, []int64{101, 102}, ways[201].Nodes)
+				assert.EqualError(t, errs[202], "HTTP status code 404")
+			},
+		},
+		{
+			name:     "should load full relation",
+			expQuery: "[out:json][timeout:25];rel(301);(._;>;);out meta;",
+			response: fullBytes,
+			testFn: func(t *testing.T, client *OSMClient) {
+				full, err := client.GetRelationFull(context.Background(), 301)
+				require.NoError(t, err)
+				assert.Equal(t, int64(301), full.Relation.ID)
+				assert.Len(t, full.Ways, 2)
+				assert.Len(t, full.Nodes, 3)
+			},
+		},
+		{
+			name:     "should load the parent relations of a node",
+			expQuery: "[out:json][timeout:25];node(101);rel(bn);out meta;",
+			response: fullBytes,
+			testFn: func(t *testing.T, client *OSMClient) {
+				relations, err := client.GetParentRelations(context.Background(), "node", 101)
+				require.NoError(t, err)
+				require.Len(t, relations, 1)
+				assert.Equal(t, int64(301), relations[0].ID)
+			},
+		},
+		{
+			name:     "should return not found for missing relation",
+			expQuery: "[out:json][timeout:25];rel(301);out meta;",
+			response: []byte(`{"elements":[]}`),
+			testFn: func(t *testing.T, client *OSMClient) {
+				_, err := client.GetRelation(context.Background(), 301)
+				assert.True(t, IsDeleted(err))
+			},
+		},
+		{
+			name:     "should load bounding box",
+			expQuery: "[out:json][timeout:25];(nwr(55.900000,-3.300000,55.950000,-3.200000););(._;>;);out meta;",
+			response: fullBytes,
+			testFn: func(t *testing.T, client *OSMClient) {
+				elements, err := client.GetBBox(context.Background(), BBox{MinLat: 55.9, MinLon: -3.3, MaxLat: 55.95, MaxLon: -3.2})
+				require.NoError(t, err)
+				assert.Len(t, elements.Relations, 1)
+				assert.Len(t, elements.Ways, 2)
+
+				//Elements should now be served from the cache
+				_, errs := client.LoadWays(context.Background(), []int64{201, 202})
+				assert.Empty(t, errs)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				require.Equal(t, 1, requests, "unexpected request")
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, tc.expQuery, r.FormValue("data"))
+				_, err := w.Write(tc.response)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}))
+			defer svr.Close()
+
+			client := NewClient("unit-test/0.0").WithOverpass(svr.URL)
+			tc.testFn(t, client)
+		})
+	}
+}
+
+func Test_parentRelations_unsupportedType(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("unexpected request")
+	}))
+	defer svr.Close()
+
+	for _, client := range []*OSMClient{NewClient("unit-test/0.0").WithBaseUrl(svr.URL), NewClient("unit-test/0.0").WithOverpass(svr.URL)} {
+		_, err := client.GetParentRelations(context.Background(), "area", 101)
+		assert.EqualError(t, err, "unsupported element type 'area'")
+	}
+}
+
+func Test_overpassRemark(t *testing.T) {
+	relationBytes, err := os.ReadFile("testdata/relation.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonRemark := []byte(`{"elements":[],"remark":"runtime error: Query timed out in \"query\" at line 1 after 26 seconds."}`)
+	xmlRemark := []byte(`<?xml version="1.0" encoding="UTF-8"?><osm version="0.6"><remark> runtime error: Query run out of memory. </remark></osm>`)
+
+	testcases := []struct {
+		name        string
+		contentType string
+		remark      []byte
+		expRemark   string
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			remark:      jsonRemark,
+			expRemark:   `runtime error: Query timed out in "query" at line 1 after 26 seconds.`,
+		},
+		{
+			name:        "XML",
+			contentType: "application/osm3s+xml",
+			remark:      xmlRemark,
+			expRemark:   "runtime error: Query run out of memory.",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name+" should not report relation as deleted", func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, err := w.Write(tc.remark)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}))
+			defer svr.Close()
+
+			client := NewClient("unit-test/0.0").WithOverpass(svr.URL).WithRetryPolicy(NoRetryPolicy())
+			_, err := client.GetRelation(context.Background(), 3411082864)
+			assert.False(t, IsDeleted(err))
+			var remarkErr OverpassRemarkError
+			require.ErrorAs(t, err, &remarkErr)
+			assert.Equal(t, tc.expRemark, remarkErr.Remark)
+		})
+
+		t.Run(tc.name+" should retry", func(t *testing.T) {
+			requests := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				response := relationBytes
+				if requests == 1 {
+					w.Header().Set("Content-Type", tc.contentType)
+					response = tc.remark
+				}
+				_, err := w.Write(response)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}))
+			defer svr.Close()
+
+			policy := RetryPolicy{MaxAttempts: 2, AttemptTimeout: time.Second, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: time.Second}
+			client := NewClient("unit-test/0.0").WithOverpass(svr.URL).WithRetryPolicy(policy)
+			relation, err := client.GetRelation(context.Background(), 3411082864)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3411082864), relation.ID)
+			assert.Equal(t, 2, requests)
+		})
+	}
+}
+
+func Test_retryPolicy(t *testing.T) {
+	assert.Equal(t, DefaultRetryPolicy(), NewClient("unit-test/0.0").getRetryPolicy())
+
+	overpass := NewClient("unit-test/0.0").WithOverpass(DefaultOverpassUrl)
+	assert.Greater(t, overpass.getRetryPolicy().AttemptTimeout, overpassQueryTimeout)
+
+	custom := NewClient("unit-test/0.0").WithRetryPolicy(NoRetryPolicy()).WithOverpass(DefaultOverpassUrl)
+	assert.Equal(t, NoRetryPolicy(), custom.getRetryPolicy())
+}
+
+func Test_withXML(t *testing.T) {
+	before := NewClient("unit-test/0.0").WithXML().WithOverpass(DefaultOverpassUrl)
+	assert.Equal(t, overpassSource{url: DefaultOverpassUrl, xml: true}, before.source)
+
+	after := NewClient("unit-test/0.0").WithBaseUrl("https://example.com/api/0.6").WithXML()
+	assert.Equal(t, apiSource{baseUrl: "https://example.com/api/0.6", xml: true}, after.source)
+}
@@ -0,0 +1,210 @@
+package osm
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Store is a Provider which holds elements in memory, so relations can be validated without making any requests.
+// Lookups of elements which are not in the store fail with HTTP status 404, and deleted elements with 410, matching
+// the OSM API
+type Store struct {
+	elements Elements
+	deleted  map[elementKey]bool
+}
+
+type elementKey struct {
+	elemType string
+	id       int64
+}
+
+func NewStore(elements Elements) *Store {
+	s := newStore()
+	for _, relation := range elements.Relations {
+		s.elements.Relations[relation.ID] = relation
+	}
+	for _, way := range elements.Ways {
+		s.elements.Ways[way.ID] = way
+	}
+	for _, node := range elements.Nodes {
+		s.elements.Nodes[node.ID] = node
+	}
+	return s
+}
+
+func newStore() *Store {
+	return &Store{
+		elements: newElements(),
+		deleted:  map[elementKey]bool{},
+	}
+}
+
+// LoadFile reads an OSM XML file, a PBF extract if the file name ends in .pbf, or an OSM API response if the file
+// name ends in .json
+func LoadFile(path string) (*Store, error) {
+	file, err := os.Open(path) // #nosec G304 -- File inclusion via variable is intentional
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch {
+	case strings.HasSuffix(path, ".pbf"):
+		return DecodePBF(file)
+	case strings.HasSuffix(path, ".json"):
+		return DecodeJSON(file)
+	default:
+		return DecodeXML(file)
+	}
+}
+
+// LoadFiles reads several files with LoadFile into a single store, e.g. a set of test fixtures
+func LoadFiles(paths ...string) (*Store, error) {
+	store := newStore()
+	for _, path := range paths {
+		fileStore, err := LoadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		store.merge(fileStore)
+	}
+	return store, nil
+}
+
+// Elements returns every element in the store
+func (s *Store) Elements() Elements {
+	return s.elements
+}
+
+func (s *Store) GetRelation(_ context.Context, relationId int64) (Relation, error) {
+	relation, found := s.elements.Relations[relationId]
+	if !found {
+		return Relation{}, s.notFound("relation", relationId)
+	}
+	return relation, nil
+}
+
+// GetRelationFull returns a relation along with its member ways and nodes. Members which are not in the store are
+// left out, as they would be by the OSM API
+func (s *Store) GetRelationFull(ctx context.Context, relationId int64) (FullRelation, error) {
+	relation, err := s.GetRelation(ctx, relationId)
+	if err != nil {
+		return FullRelation{}, err
+	}
+
+	full := FullRelation{Relation: relation, Ways: map[int64]Way{}, Nodes: map[int64]Node{}}
+	for _, member := range relation.Members {
+		switch member.Type {
+		case "node":
+			if node, found := s.elements.Nodes[member.Ref]; found {
+				full.Nodes[node.ID] = node
+			}
+		case "way":
+			way, found := s.elements.Ways[member.Ref]
+			if !found {
+				continue
+			}
+			full.Ways[way.ID] = way
+			for _, nodeId := range way.Nodes {
+				if node, found := s.elements.Nodes[nodeId]; found {
+					full.Nodes[nodeId] = node
+				}
+			}
+		}
+	}
+	return full, nil
+}
+
+// GetRelationRelations returns the relations which have the relation as a member, ordered by ID
+func (s *Store) GetRelationRelations(ctx context.Context, relationId int64) ([]Relation, error) {
+	return s.GetParentRelations(ctx, "relation", relationId)
+}
+
+// GetParentRelations returns the relations which have a node, way or relation as a member, ordered by ID
+func (s *Store) GetParentRelations(_ context.Context, elemType string, id int64) ([]Relation, error) {
+	relations := []Relation{}
+	for _, relation := range s.elements.Relations {
+		for _, member := range relation.Members {
+			if member.Type == elemType && member.Ref == id {
+				relations = append(relations, relation)
+				break
+			}
+		}
+	}
+	slices.SortFunc(relations, func(a, b Relation) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return relations, nil
+}
+
+func (s *Store) LoadWays(_ context.Context, wayIds []int64) (map[int64]*Way, map[int64]error) {
+	wayMap := map[int64]*Way{}
+	errs := map[int64]error{}
+	for _, wayId := range wayIds {
+		way, found := s.elements.Ways[wayId]
+		if !found {
+			errs[wayId] = s.notFound("way", wayId)
+			continue
+		}
+		wayMap[wayId] = &way
+	}
+	return wayMap, errs
+}
+
+func (s *Store) LoadNodes(_ context.Context, nodeIds []int64) (map[int64]*Node, map[int64]error) {
+	nodeMap := map[int64]*Node{}
+	errs := map[int64]error{}
+	for _, nodeId := range nodeIds {
+		node, found := s.elements.Nodes[nodeId]
+		if !found {
+			errs[nodeId] = s.notFound("node", nodeId)
+			continue
+		}
+		nodeMap[nodeId] = &node
+	}
+	return nodeMap, errs
+}
+
+func (s *Store) addNode(node Node, visible bool) {
+	if !visible {
+		s.deleted[elementKey{"node", node.ID}] = true
+		return
+	}
+	s.elements.Nodes[node.ID] = node
+}
+
+func (s *Store) addWay(way Way, visible bool) {
+	if !visible {
+		s.deleted[elementKey{"way", way.ID}] = true
+		return
+	}
+	s.elements.Ways[way.ID] = way
+}
+
+func (s *Store) addRelation(relation Relation, visible bool) {
+	if !visible {
+		s.deleted[elementKey{"relation", relation.ID}] = true
+		return
+	}
+	s.elements.Relations[relation.ID] = relation
+}
+
+func (s *Store) merge(other *Store) {
+	maps.Copy(s.elements.Relations, other.elements.Relations)
+	maps.Copy(s.elements.Ways, other.elements.Ways)
+	maps.Copy(s.elements.Nodes, other.elements.Nodes)
+	maps.Copy(s.deleted, other.deleted)
+}
+
+func (s *Store) notFound(elemType string, id int64) error {
+	if s.deleted[elementKey{elemType, id}] {
+		return HttpStatusError{StatusCode: http.StatusGone}
+	}
+	return HttpStatusError{StatusCode: http.StatusNotFound}
+}
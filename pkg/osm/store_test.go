@@ -0,0 +1,92 @@
+package osm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadFile(t *testing.T) {
+	data, err := os.ReadFile("testdata/relation_full.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := decodeResponse("application/json", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := res.elements()
+
+	testcases := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "OSM XML",
+			path: "testdata/relation_full.osm",
+		},
+		{
+			name: "PBF",
+			path: "testdata/relation_full.osm.pbf",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := LoadFile(tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, store.Elements())
+
+			ctx := context.Background()
+			full, err := store.GetRelationFull(ctx, 301)
+			require.NoError(t, err)
+			assert.Len(t, full.Ways, 2)
+			assert.Len(t, full.Nodes, 3)
+
+			ways, errs := store.LoadWays(ctx, []int64{201, 203, 204})
+			assert.Len(t, ways, 1)
+			assert.EqualError(t, errs[203], "HTTP status code 410")
+			assert.EqualError(t, errs[204], "HTTP status code 404")
+
+			_, err = store.GetRelation(ctx, 302)
+			assert.EqualError(t, err, "HTTP status code 404")
+		})
+	}
+}
+
+func Test_loadFiles(t *testing.T) {
+	store, err := LoadFiles("testdata/relation_full.osm", "testdata/relation_relations.json")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = store.GetRelation(ctx, 301)
+	assert.NoError(t, err)
+
+	relations, err := store.GetRelationRelations(ctx, 11562232)
+	require.NoError(t, err)
+	require.Len(t, relations, 1)
+	assert.Equal(t, int64(3009058), relations[0].ID)
+
+	relations, err = store.GetParentRelations(ctx, "way", 201)
+	require.NoError(t, err)
+	require.Len(t, relations, 1)
+	assert.Equal(t, int64(301), relations[0].ID)
+
+	_, err = LoadFiles("testdata/relation_full.osm", "testdata/missing.osm")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_decodePBF_truncated(t *testing.T) {
+	data, err := os.ReadFile("testdata/relation_full.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DecodePBF(bytes.NewReader(data[:len(data)-10]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -1,13 +1,20 @@
 package osm
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Way struct {
-	Type    string            `json:"type"`
-	ID      int64             `json:"id"`
-	Version int32             `json:"version"`
-	Nodes   []int64           `json:"nodes"`
-	Tags    map[string]string `json:"tags"`
+	Type      string            `json:"type"`
+	ID        int64             `json:"id"`
+	Version   int32             `json:"version"`
+	Timestamp time.Time         `json:"timestamp,omitzero"`
+	Changeset int64             `json:"changeset,omitempty"`
+	User      string            `json:"user,omitempty"`
+	UID       int64             `json:"uid,omitempty"`
+	Nodes     []int64           `json:"nodes"`
+	Tags      map[string]string `json:"tags"`
 }
 
 func (w *Way) GetTags() map[string]string {
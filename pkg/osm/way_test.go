@@ -1,7 +1,6 @@
 package osm
 
 import (
-	"encoding/json"
 	"os"
 	"testing"
 
@@ -14,11 +13,10 @@ func Test_unmarshallWay(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	var way wayResponse
-	err = json.Unmarshal(bytes, &way)
+	res, err := decodeResponse("application/json", bytes)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, int64(2154620362), way.Elements[0].ID)
+	assert.Equal(t, int64(2154620362), res.ways[0].ID)
 }
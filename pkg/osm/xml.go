@@ -0,0 +1,147 @@
+package osm
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// DecodeXML reads the elements from an OSM XML file, as exported by the OSM API or JOSM
+func DecodeXML(r io.Reader) (*Store, error) {
+	store := newStore()
+	err := readXML(r, store)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func readXML(r io.Reader, sink elementSink) error {
+	decoder := xml.NewDecoder(r)
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "remark" {
+			var remark string
+			err = decoder.DecodeElement(&remark, &start)
+			if err != nil {
+				return err
+			}
+			return OverpassRemarkError{Remark: strings.TrimSpace(remark)}
+		}
+		err = decodeXMLElement(decoder, start, sink)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decodeXMLElement decodes a node, way or relation and adds it to the sink. Any other element is ignored, so the
+// caller carries on reading its children
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement, sink elementSink) error {
+	switch start.Name.Local {
+	case "node":
+		var n xmlNode
+		err := decoder.DecodeElement(&n, &start)
+		if err != nil {
+			return err
+		}
+		node := Node{Type: "node", ID: n.ID, Lat: n.Lat, Lon: n.Lon, Version: n.Version, Timestamp: n.Timestamp, Changeset: n.Changeset, User: n.User, UID: n.UID, Tags: getXMLTags(n.Tags)}
+		sink.addNode(node, n.Visible != "false")
+	case "way":
+		var w xmlWay
+		err := decoder.DecodeElement(&w, &start)
+		if err != nil {
+			return err
+		}
+		way := Way{Type: "way", ID: w.ID, Version: w.Version, Timestamp: w.Timestamp, Changeset: w.Changeset, User: w.User, UID: w.UID, Nodes: []int64{}, Tags: getXMLTags(w.Tags)}
+		for _, nd := range w.Nodes {
+			way.Nodes = append(way.Nodes, nd.Ref)
+		}
+		sink.addWay(way, w.Visible != "false")
+	case "relation":
+		var r xmlRelation
+		err := decoder.DecodeElement(&r, &start)
+		if err != nil {
+			return err
+		}
+		relation := Relation{Type: "relation", ID: r.ID, Version: r.Version, Timestamp: r.Timestamp, Changeset: r.Changeset, User: r.User, UID: r.UID, Members: []Member{}, Tags: getXMLTags(r.Tags)}
+		for _, m := range r.Members {
+			relation.Members = append(relation.Members, Member{Type: m.Type, Ref: m.Ref, Role: m.Role})
+		}
+		sink.addRelation(relation, r.Visible != "false")
+	}
+	return nil
+}
+
+func getXMLTags(xmlTags []xmlTag) map[string]string {
+	if len(xmlTags) < 1 {
+		return nil
+	}
+	tags := map[string]string{}
+	for _, tag := range xmlTags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+type xmlTag struct {
+	Key   string `xml:"k,attr"`
+	Value string `xml:"v,attr"`
+}
+
+type xmlNode struct {
+	ID        int64     `xml:"id,attr"`
+	Version   int32     `xml:"version,attr"`
+	Visible   string    `xml:"visible,attr"`
+	Timestamp time.Time `xml:"timestamp,attr"`
+	Changeset int64     `xml:"changeset,attr"`
+	User      string    `xml:"user,attr"`
+	UID       int64     `xml:"uid,attr"`
+	Lat       float64   `xml:"lat,attr"`
+	Lon       float64   `xml:"lon,attr"`
+	Tags      []xmlTag  `xml:"tag"`
+}
+
+type xmlWay struct {
+	ID        int64     `xml:"id,attr"`
+	Version   int32     `xml:"version,attr"`
+	Visible   string    `xml:"visible,attr"`
+	Timestamp time.Time `xml:"timestamp,attr"`
+	Changeset int64     `xml:"changeset,attr"`
+	User      string    `xml:"user,attr"`
+	UID       int64     `xml:"uid,attr"`
+	Tags      []xmlTag  `xml:"tag"`
+	Nodes     []struct {
+		Ref int64 `xml:"ref,attr"`
+	} `xml:"nd"`
+}
+
+type xmlRelation struct {
+	ID        int64     `xml:"id,attr"`
+	Version   int32     `xml:"version,attr"`
+	Visible   string    `xml:"visible,attr"`
+	Timestamp time.Time `xml:"timestamp,attr"`
+	Changeset int64     `xml:"changeset,attr"`
+	User      string    `xml:"user,attr"`
+	UID       int64     `xml:"uid,attr"`
+	Tags      []xmlTag  `xml:"tag"`
+	Members   []struct {
+		Type string `xml:"type,attr"`
+		Ref  int64  `xml:"ref,attr"`
+		Role string `xml:"role,attr"`
+	} `xml:"member"`
+}
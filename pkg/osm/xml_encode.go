@@ -0,0 +1,162 @@
+package osm
+
+import (
+	"encoding/xml"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+const xmlGenerator = "osm-pt-validator"
+
+// EncodeOsmChange writes an osmChange file with every element in a changeset. The file can be uploaded to
+// /changeset/{id}/upload if the changeset is open, and the API rejects any element whose version is not the latest, so
+// edits made since the elements were loaded are not overwritten. If changesetId is 0 the changeset is left out, and
+// the file can't be uploaded until one is added, e.g. by opening it in JOSM
+func EncodeOsmChange(w io.Writer, change *OsmChange, changesetId int64) error {
+	out := xmlOsmChange{
+		Version:   "0.6",
+		Generator: xmlGenerator,
+		Create:    encodeActionElements(change.Create, "", changesetId),
+		Modify:    encodeActionElements(change.Modify, "", changesetId),
+		Delete:    encodeActionElements(change.Delete, "", changesetId),
+	}
+	return encodeXMLDocument(w, out)
+}
+
+// EncodeJOSM writes an OSM XML file for JOSM with every element marked as modified, so that it can be reviewed in JOSM
+// and uploaded from there
+func EncodeJOSM(w io.Writer, modified Elements) error {
+	out := xmlOsm{Version: "0.6", Generator: xmlGenerator}
+	if elements := encodeActionElements(modified, "modify", 0); elements != nil {
+		out.xmlActionElements = *elements
+	}
+	return encodeXMLDocument(w, out)
+}
+
+func encodeXMLDocument(w io.Writer, v any) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(v)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func encodeActionElements(elements Elements, action string, changesetId int64) *xmlActionElements {
+	if len(elements.Nodes)+len(elements.Ways)+len(elements.Relations) == 0 {
+		return nil
+	}
+
+	out := &xmlActionElements{}
+	for _, id := range slices.Sorted(maps.Keys(elements.Nodes)) {
+		n := elements.Nodes[id]
+		out.Nodes = append(out.Nodes, xmlEncodeNode{
+			xmlEncodeElement: encodeElement(n.ID, n.Version, n.Timestamp, action, changesetId),
+			Lat:              n.Lat,
+			Lon:              n.Lon,
+			Tags:             encodeTags(n.Tags),
+		})
+	}
+	for _, id := range slices.Sorted(maps.Keys(elements.Ways)) {
+		w := elements.Ways[id]
+		way := xmlEncodeWay{xmlEncodeElement: encodeElement(w.ID, w.Version, w.Timestamp, action, changesetId), Tags: encodeTags(w.Tags)}
+		for _, nodeId := range w.Nodes {
+			way.Nodes = append(way.Nodes, xmlNodeRef{Ref: nodeId})
+		}
+		out.Ways = append(out.Ways, way)
+	}
+	for _, id := range slices.Sorted(maps.Keys(elements.Relations)) {
+		r := elements.Relations[id]
+		relation := xmlEncodeRelation{xmlEncodeElement: encodeElement(r.ID, r.Version, r.Timestamp, action, changesetId), Tags: encodeTags(r.Tags)}
+		for _, m := range r.Members {
+			relation.Members = append(relation.Members, xmlMember{Type: m.Type, Ref: m.Ref, Role: m.Role})
+		}
+		out.Relations = append(out.Relations, relation)
+	}
+	return out
+}
+
+func encodeElement(id int64, version int32, timestamp time.Time, action string, changesetId int64) xmlEncodeElement {
+	element := xmlEncodeElement{ID: id, Version: version, Action: action, Changeset: changesetId}
+	if !timestamp.IsZero() {
+		element.Timestamp = timestamp.UTC().Format(time.RFC3339)
+	}
+	return element
+}
+
+func encodeTags(tags map[string]string) []xmlTag {
+	xmlTags := []xmlTag{}
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		xmlTags = append(xmlTags, xmlTag{Key: key, Value: tags[key]})
+	}
+	return xmlTags
+}
+
+type xmlOsmChange struct {
+	XMLName   xml.Name           `xml:"osmChange"`
+	Version   string             `xml:"version,attr"`
+	Generator string             `xml:"generator,attr"`
+	Create    *xmlActionElements `xml:"create,omitempty"`
+	Modify    *xmlActionElements `xml:"modify,omitempty"`
+	Delete    *xmlActionElements `xml:"delete,omitempty"`
+}
+
+type xmlOsm struct {
+	XMLName   xml.Name `xml:"osm"`
+	Version   string   `xml:"version,attr"`
+	Generator string   `xml:"generator,attr"`
+	xmlActionElements
+}
+
+type xmlActionElements struct {
+	Nodes     []xmlEncodeNode     `xml:"node"`
+	Ways      []xmlEncodeWay      `xml:"way"`
+	Relations []xmlEncodeRelation `xml:"relation"`
+}
+
+// xmlEncodeElement has the attributes common to every element. The changeset is the one the edit will be uploaded in,
+// not the one the element was last edited in, and the user is left out as it is set when the edit is uploaded
+type xmlEncodeElement struct {
+	ID        int64  `xml:"id,attr"`
+	Action    string `xml:"action,attr,omitempty"`
+	Version   int32  `xml:"version,attr"`
+	Changeset int64  `xml:"changeset,attr,omitempty"`
+	Timestamp string `xml:"timestamp,attr,omitempty"`
+}
+
+type xmlEncodeNode struct {
+	xmlEncodeElement
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Tags []xmlTag `xml:"tag"`
+}
+
+type xmlEncodeWay struct {
+	xmlEncodeElement
+	Nodes []xmlNodeRef `xml:"nd"`
+	Tags  []xmlTag     `xml:"tag"`
+}
+
+type xmlEncodeRelation struct {
+	xmlEncodeElement
+	Members []xmlMember `xml:"member"`
+	Tags    []xmlTag    `xml:"tag"`
+}
+
+type xmlNodeRef struct {
+	Ref int64 `xml:"ref,attr"`
+}
+
+type xmlMember struct {
+	Type string `xml:"type,attr"`
+	Ref  int64  `xml:"ref,attr"`
+	Role string `xml:"role,attr"`
+}
@@ -0,0 +1,65 @@
+package osm
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testModifiedElements() Elements {
+	elements := newElements()
+	elements.Relations[301] = Relation{
+		Type:      "relation",
+		ID:        301,
+		Version:   5,
+		Timestamp: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+		Changeset: 160000004,
+		User:      "Mapper B",
+		Members:   []Member{{Type: "node", Ref: 101, Role: RoleStop}, {Type: "way", Ref: 202, Role: ""}, {Type: "way", Ref: 201, Role: ""}},
+		Tags:      map[string]string{"type": "route", "route": "bus", "name": "1: A => B & C"},
+	}
+	return elements
+}
+
+func TestEncodeOsmChange(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := EncodeOsmChange(buf, &OsmChange{Create: newElements(), Modify: testModifiedElements(), Delete: newElements()}, 0)
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), "<create>")
+	//The changeset the relation was last edited in must not be uploaded
+	assert.NotContains(t, buf.String(), "changeset=")
+
+	change, err := DecodeOsmChange(buf)
+	require.NoError(t, err)
+	relation := change.Modify.Relations[301]
+	expected := testModifiedElements().Relations[301]
+	assert.Equal(t, expected.Version, relation.Version)
+	assert.Equal(t, expected.Timestamp, relation.Timestamp)
+	assert.Equal(t, expected.Members, relation.Members)
+	assert.Equal(t, expected.Tags, relation.Tags)
+}
+
+func TestEncodeOsmChange_changeset(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := EncodeOsmChange(buf, &OsmChange{Modify: testModifiedElements()}, 170000001)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `<relation id="301" version="5" changeset="170000001"`)
+
+	change, err := DecodeOsmChange(buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(170000001), change.Modify.Relations[301].Changeset)
+}
+
+func TestEncodeJOSM(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := EncodeJOSM(buf, testModifiedElements())
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `<relation id="301" action="modify" version="5"`)
+
+	store, err := DecodeXML(buf)
+	require.NoError(t, err)
+	assert.Equal(t, testModifiedElements().Relations[301].Members, store.Elements().Relations[301].Members)
+}
@@ -0,0 +1,103 @@
+package osm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const waysXML = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="CGImap">
+  <way id="201" visible="true" version="7">
+    <nd ref="101"/>
+    <nd ref="102"/>
+    <tag k="highway" v="tertiary"/>
+  </way>
+  <way id="203" visible="false" version="5"/>
+</osm>`
+
+func Test_xmlResponses(t *testing.T) {
+	fullXML, err := os.ReadFile("testdata/relation_full.osm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name     string
+		xml      bool
+		expPath  string
+		response string
+		testFn   func(t *testing.T, client *OSMClient)
+	}{
+		{
+			name:     "should load full relation",
+			xml:      true,
+			expPath:  "/relation/301/full",
+			response: string(fullXML),
+			testFn: func(t *testing.T, client *OSMClient) {
+				full, err := client.GetRelationFull(context.Background(), 301)
+				require.NoError(t, err)
+				assert.Equal(t, int32(12), full.Relation.Version)
+				assert.Equal(t, "bus", full.Relation.Tags["route"])
+				assert.Equal(t, Member{Type: "node", Ref: 101, Role: "stop"}, full.Relation.Members[0])
+				assert.Equal(t, []int64{102, 103}, full.Ways[202].Nodes)
+				assert.Equal(t, 55.9214041, full.Nodes[101].Lat)
+			},
+		},
+		{
+			name:     "should load ways and report deleted ways",
+			xml:      true,
+			expPath:  "/ways?ways=201,203",
+			response: waysXML,
+			testFn: func(t *testing.T, client *OSMClient) {
+				ways, errs := client.LoadWays(context.Background(), []int64{201, 203})
+				require.NotNil(t, ways[201])
+				assert.Equal(t, []int64{101, 102}, ways[201].Nodes)
+				assert.EqualError(t, errs[203], "HTTP status code 410")
+			},
+		},
+		{
+			name:     "should decode XML returned for a JSON request",
+			expPath:  "/way/201.json",
+			response: waysXML,
+			testFn: func(t *testing.T, client *OSMClient) {
+				way, err := client.GetWay(context.Background(), 201)
+				require.NoError(t, err)
+				assert.Equal(t, "tertiary", way.Tags["highway"])
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tc.expPath, r.RequestURI)
+				w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+				_, err := w.Write([]byte(tc.response))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}))
+			defer svr.Close()
+
+			client := NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
+			if tc.xml {
+				client.WithXML()
+			}
+			tc.testFn(t, client)
+		})
+	}
+}
+
+func Test_isXMLContentType(t *testing.T) {
+	assert.True(t, isXMLContentType("application/xml; charset=utf-8"))
+	assert.True(t, isXMLContentType("text/xml"))
+	assert.True(t, isXMLContentType("application/osm3s+xml"))
+	assert.False(t, isXMLContentType("application/json; charset=utf-8"))
+	assert.False(t, isXMLContentType(""))
+}
@@ -0,0 +1,42 @@
+package replication
+
+import (
+	"context"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/events"
+)
+
+// MaxDiffsPerRun limits how many diffs CheckDiffs processes, so that catching up after an outage is spread over several
+// runs
+const MaxDiffsPerRun = 60
+
+// CheckDiffs loads the diffs after the last processed sequence number, matches them to the watched routes and updates
+// the index. It returns an event for each affected route and the sequence number of the last diff processed. An index
+// which is being built, if any, is updated too so that it doesn't miss edits made while it is built
+func CheckDiffs(ctx context.Context, client *Client, index *Index, building *Index, lastSequence int64) ([]events.CheckRelationEvent, int64, error) {
+	state, err := client.GetState(ctx)
+	if err != nil {
+		return nil, lastSequence, err
+	}
+	to := min(state.SequenceNumber, lastSequence+MaxDiffsPerRun)
+
+	matched := map[int64]bool{}
+	checkEvents := []events.CheckRelationEvent{}
+	for sequence := lastSequence + 1; sequence <= to; sequence++ {
+		change, err := client.GetDiff(ctx, sequence)
+		if err != nil {
+			return checkEvents, sequence - 1, err
+		}
+		for _, event := range index.Match(change) {
+			if !matched[event.RelationID] {
+				matched[event.RelationID] = true
+				checkEvents = append(checkEvents, event)
+			}
+		}
+		index.Update(change)
+		if building != nil {
+			building.Update(change)
+		}
+	}
+	return checkEvents, to, nil
+}
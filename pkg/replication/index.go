@@ -0,0 +1,241 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/events"
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/ockendenjo/osm-pt-validator/pkg/routes"
+	"github.com/ockendenjo/osm-pt-validator/pkg/validation"
+)
+
+// Index maps the relations, ways and nodes of the watched route relations to the routes which contain them, so that
+// replication diffs can be matched to the routes they affect
+type Index struct {
+	// Routes is the validation config of each watched relation
+	Routes map[int64]validation.Config `json:"routes"`
+	// Elements is keyed by element type and ID, e.g. way/123
+	Elements map[string][]int64 `json:"elements"`
+	// Pending are the relations still to be loaded while the index is being built
+	Pending []PendingRelation `json:"pending,omitempty"`
+	// Failed are the errors of relations which could not be loaded, keyed by relation ID. Only their IDs are watched
+	Failed  map[int64]string `json:"failed,omitempty"`
+	BuiltAt time.Time        `json:"builtAt"`
+}
+
+// PendingRelation is a relation in the routes files which has not been loaded into the index yet
+type PendingRelation struct {
+	RelationID int64             `json:"relationId"`
+	Config     validation.Config `json:"config"`
+	// Attempts is the number of times loading the relation has failed
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// maxLoadAttempts is how many times the relations in the index are tried before only their IDs are watched
+const maxLoadAttempts = 3
+
+func NewIndex() *Index {
+	return &Index{Routes: map[int64]validation.Config{}, Elements: map[string][]int64{}, BuiltAt: time.Now().UTC()}
+}
+
+// NewIndexBuild starts building an index of every route in the routes files. Nothing is loaded until Build is called
+func NewIndexBuild(files []routes.RoutesFile) *Index {
+	index := NewIndex()
+	for _, file := range files {
+		for _, routeList := range file.Routes {
+			for _, route := range routeList {
+				if route.RelationID < 1 || route.Skip {
+					continue
+				}
+				index.Pending = append(index.Pending, PendingRelation{RelationID: route.RelationID, Config: file.Config})
+			}
+		}
+	}
+	return index
+}
+
+// BuildIndex loads every route in the routes files. The variants of route masters are watched as routes in their own
+// right, with the config of the routes file
+func BuildIndex(ctx context.Context, provider osm.Provider, files []routes.RoutesFile) (*Index, error) {
+	index := NewIndexBuild(files)
+	err := index.Build(ctx, provider, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Build loads pending relations until the deadline, or all of them if the deadline is zero, so that a large index can
+// be built over several runs and saved in between. At least one relation is loaded, so every call makes progress. The
+// variants of route masters are added to the pending relations. A relation which fails to load is tried again after
+// the other pending relations, and is added to Failed once it has failed maxLoadAttempts times. An error is only
+// returned if the context is done, in which case the relations loaded so far are kept
+func (i *Index) Build(ctx context.Context, provider osm.Provider, deadline time.Time) error {
+	for loaded := 0; len(i.Pending) > 0; loaded++ {
+		if loaded > 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		pending := i.Pending[0]
+		variants, err := i.addRelation(ctx, provider, pending.RelationID, pending.Config)
+		if ctx.Err() != nil {
+			//The relation is left pending, as it only failed because the caller gave up
+			return ctx.Err()
+		}
+		i.Pending = i.Pending[1:]
+		if err != nil {
+			i.addFailure(pending, err)
+			continue
+		}
+		for _, variantId := range variants {
+			i.Pending = append(i.Pending, PendingRelation{RelationID: variantId, Config: pending.Config})
+		}
+	}
+	if i.IsBuilt() {
+		i.BuiltAt = time.Now().UTC()
+	}
+	return nil
+}
+
+// addFailure moves a relation which failed to load to the back of the pending relations, so that it can't hold up the
+// build. Once it has failed too many times, its ID is watched so that it is still checked if it is edited
+func (i *Index) addFailure(pending PendingRelation, err error) {
+	pending.Attempts++
+	if pending.Attempts < maxLoadAttempts {
+		i.Pending = append(i.Pending, pending)
+		return
+	}
+	i.Add(osm.FullRelation{Relation: osm.Relation{ID: pending.RelationID}}, pending.Config)
+	if i.Failed == nil {
+		i.Failed = map[int64]string{}
+	}
+	i.Failed[pending.RelationID] = err.Error()
+}
+
+// IsBuilt is whether every relation in the routes files has been loaded
+func (i *Index) IsBuilt() bool {
+	return len(i.Pending) == 0
+}
+
+// addRelation watches a relation, and returns the IDs of the variants to load if it is a route master
+func (i *Index) addRelation(ctx context.Context, provider osm.Provider, relationId int64, config validation.Config) ([]int64, error) {
+	full, err := provider.GetRelationFull(ctx, relationId)
+	if osm.IsDeleted(err) {
+		//Watch the ID anyway, so that the relation is checked if it is restored
+		i.Add(osm.FullRelation{Relation: osm.Relation{ID: relationId}}, config)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if full.Relation.Tags["type"] != "route_master" {
+		err = validation.NewValidator(config, provider).AddStopAreas(ctx, &full)
+		if err != nil {
+			return nil, err
+		}
+		i.Add(full, config)
+		return nil, nil
+	}
+	i.Add(osm.FullRelation{Relation: full.Relation}, config)
+	variants := []int64{}
+	for _, member := range full.Relation.Members {
+		if member.Type == "relation" {
+			variants = append(variants, member.Ref)
+		}
+	}
+	return variants, nil
+}
+
+// Add watches a relation and the ways, nodes and relations loaded with it, e.g. stop areas. The variants of a route
+// master are watched for the route master too, as it is checked against their tags. Other member relations are not
+// watched unless they were loaded
+func (i *Index) Add(full osm.FullRelation, config validation.Config) {
+	relationId := full.Relation.ID
+	isRouteMaster := full.Relation.Tags["type"] == "route_master"
+	i.Routes[relationId] = config
+	i.watch(getKey("relation", relationId), relationId)
+	for _, member := range full.Relation.Members {
+		if member.Type != "relation" || isRouteMaster {
+			i.watch(getKey(member.Type, member.Ref), relationId)
+		}
+	}
+	for _, way := range full.Ways {
+		i.watch(getKey("way", way.ID), relationId)
+	}
+	for _, node := range full.Nodes {
+		i.watch(getKey("node", node.ID), relationId)
+	}
+	for _, relation := range full.Relations {
+		i.watch(getKey("relation", relation.ID), relationId)
+	}
+}
+
+func (i *Index) watch(key string, relationId int64) {
+	if !slices.Contains(i.Elements[key], relationId) {
+		i.Elements[key] = append(i.Elements[key], relationId)
+	}
+}
+
+// Match returns an event for each watched relation which contains an element in the change, ordered by relation ID
+func (i *Index) Match(change *osm.OsmChange) []events.CheckRelationEvent {
+	matched := map[int64]bool{}
+	for _, key := range getChangedKeys(change) {
+		for _, relationId := range i.Elements[key] {
+			matched[relationId] = true
+		}
+	}
+
+	relationIds := []int64{}
+	for relationId := range matched {
+		relationIds = append(relationIds, relationId)
+	}
+	slices.Sort(relationIds)
+
+	checkEvents := []events.CheckRelationEvent{}
+	for _, relationId := range relationIds {
+		checkEvents = append(checkEvents, events.CheckRelationEvent{RelationID: relationId, Config: i.Routes[relationId]})
+	}
+	return checkEvents
+}
+
+// Update watches the new members of watched relations and the new nodes of watched ways. The nodes of ways which have
+// been added to a route are only watched once the index is built again
+func (i *Index) Update(change *osm.OsmChange) {
+	for _, elements := range []osm.Elements{change.Create, change.Modify} {
+		for _, relation := range elements.Relations {
+			if _, watched := i.Routes[relation.ID]; watched {
+				i.Add(osm.FullRelation{Relation: relation}, i.Routes[relation.ID])
+			}
+		}
+		for _, way := range elements.Ways {
+			for _, relationId := range i.Elements[getKey("way", way.ID)] {
+				for _, nodeId := range way.Nodes {
+					i.watch(getKey("node", nodeId), relationId)
+				}
+			}
+		}
+	}
+}
+
+func getChangedKeys(change *osm.OsmChange) []string {
+	keys := []string{}
+	for _, elements := range []osm.Elements{change.Create, change.Modify, change.Delete} {
+		for id := range elements.Relations {
+			keys = append(keys, getKey("relation", id))
+		}
+		for id := range elements.Ways {
+			keys = append(keys, getKey("way", id))
+		}
+		for id := range elements.Nodes {
+			keys = append(keys, getKey("node", id))
+		}
+	}
+	return keys
+}
+
+func getKey(elemType string, id int64) string {
+	return fmt.Sprintf("%s/%d", elemType, id)
+}
@@ -0,0 +1,207 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/ockendenjo/osm-pt-validator/pkg/routes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestIndex(t *testing.T) *Index {
+	store, err := osm.LoadFile("testdata/routes.osm")
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/routes.json")
+	require.NoError(t, err)
+	var file routes.RoutesFile
+	require.NoError(t, json.Unmarshal(data, &file))
+
+	index, err := BuildIndex(context.Background(), store, []routes.RoutesFile{file})
+	require.NoError(t, err)
+	return index
+}
+
+func getRelationIds(t *testing.T, index *Index, change *osm.OsmChange) []int64 {
+	ids := []int64{}
+	for _, event := range index.Match(change) {
+		assert.False(t, event.Config.NaptanPlatformTags)
+		ids = append(ids, event.RelationID)
+	}
+	return ids
+}
+
+func TestBuildIndex(t *testing.T) {
+	index := loadTestIndex(t)
+	assert.Len(t, index.Routes, 3)
+	assert.Equal(t, []int64{301}, index.Elements["node/102"])
+	assert.Equal(t, []int64{400}, index.Elements["relation/400"])
+	assert.NotContains(t, index.Routes, int64(303))
+}
+
+func TestIndex_Build(t *testing.T) {
+	store, err := osm.LoadFile("testdata/routes.osm")
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/routes.json")
+	require.NoError(t, err)
+	var file routes.RoutesFile
+	require.NoError(t, json.Unmarshal(data, &file))
+
+	//With the deadline already passed, each call loads a single relation, as each run of the Lambda would if the OSM
+	//API was slow
+	index := NewIndexBuild([]routes.RoutesFile{file})
+	calls := 0
+	for !index.IsBuilt() {
+		require.NoError(t, index.Build(context.Background(), store, time.Now().Add(-time.Second)))
+		calls++
+
+		//The index is saved between runs
+		saved, err := json.Marshal(index)
+		require.NoError(t, err)
+		index = &Index{}
+		require.NoError(t, json.Unmarshal(saved, index))
+	}
+	//Route master 400 and route 302 from the routes file, then the variant of 400
+	assert.Equal(t, 3, calls)
+
+	expected := loadTestIndex(t)
+	assert.Equal(t, expected.Routes, index.Routes)
+	assert.Equal(t, expected.Elements, index.Elements)
+}
+
+// failingProvider fails to load a relation a number of times before loading it
+type failingProvider struct {
+	osm.Provider
+	relationId int64
+	failures   int
+}
+
+func (p *failingProvider) GetRelationFull(ctx context.Context, relationId int64) (osm.FullRelation, error) {
+	if relationId == p.relationId && p.failures > 0 {
+		p.failures--
+		return osm.FullRelation{}, errors.New("overpass remark: runtime error: Query timed out")
+	}
+	return p.Provider.GetRelationFull(ctx, relationId)
+}
+
+func TestIndex_Build_failures(t *testing.T) {
+	store, err := osm.LoadFile("testdata/routes.osm")
+	require.NoError(t, err)
+	data, err := os.ReadFile("testdata/routes.json")
+	require.NoError(t, err)
+	var file routes.RoutesFile
+	require.NoError(t, json.Unmarshal(data, &file))
+	expected := loadTestIndex(t)
+
+	t.Run("should load relation after the others if it fails", func(t *testing.T) {
+		provider := &failingProvider{Provider: store, relationId: 400, failures: 1}
+		index := NewIndexBuild([]routes.RoutesFile{file})
+		require.NoError(t, index.Build(context.Background(), provider, time.Now().Add(-time.Second)))
+		assert.Equal(t, []PendingRelation{{RelationID: 302}, {RelationID: 400, Attempts: 1}}, index.Pending)
+
+		require.NoError(t, index.Build(context.Background(), provider, time.Time{}))
+		assert.True(t, index.IsBuilt())
+		assert.Empty(t, index.Failed)
+		assert.Equal(t, expected.Elements, index.Elements)
+	})
+
+	t.Run("should watch ID of relation which keeps failing", func(t *testing.T) {
+		provider := &failingProvider{Provider: store, relationId: 400, failures: maxLoadAttempts}
+		index, err := BuildIndex(context.Background(), provider, []routes.RoutesFile{file})
+		require.NoError(t, err)
+		assert.Equal(t, map[int64]string{400: "overpass remark: runtime error: Query timed out"}, index.Failed)
+		assert.Equal(t, []int64{400}, index.Elements["relation/400"])
+		assert.Contains(t, index.Routes, int64(302))
+		assert.NotContains(t, index.Routes, int64(301))
+	})
+
+	t.Run("should keep relation pending if context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		index := NewIndexBuild([]routes.RoutesFile{file})
+		provider := &failingProvider{Provider: store, relationId: 400, failures: 1}
+		assert.ErrorIs(t, index.Build(ctx, provider, time.Time{}), context.Canceled)
+		assert.Equal(t, []PendingRelation{{RelationID: 400}, {RelationID: 302}}, index.Pending)
+	})
+}
+
+func TestBuildIndex_stopAreas(t *testing.T) {
+	store, err := osm.LoadFile("testdata/routes.osm")
+	require.NoError(t, err)
+	file := routes.RoutesFile{Routes: map[string][]routes.Route{"Lothian": {{Name: "1", RelationID: 301}}}}
+
+	index, err := BuildIndex(context.Background(), store, []routes.RoutesFile{file})
+	require.NoError(t, err)
+	assert.NotContains(t, index.Elements, "relation/500")
+
+	//Stop areas are only watched if the config checks them
+	file.Config.StopAreas = true
+	index, err = BuildIndex(context.Background(), store, []routes.RoutesFile{file})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{301}, index.Elements["relation/500"])
+}
+
+func TestIndex_Match(t *testing.T) {
+	testcases := []struct {
+		name   string
+		osc    string
+		expIds []int64
+	}{
+		{
+			name: "should match routes containing a changed node",
+			osc: `<osmChange><modify>
+				<node id="102" version="2" lat="55.9" lon="-3.2"/>
+				<node id="105" version="2" lat="55.9" lon="-3.2"/>
+			</modify></osmChange>`,
+			expIds: []int64{301, 302},
+		},
+		{
+			name:   "should match route masters and routes which were edited",
+			osc:    `<osmChange><modify><relation id="400" version="2"/><relation id="302" version="2"/></modify></osmChange>`,
+			expIds: []int64{302, 400},
+		},
+		{
+			name:   "should match route masters of routes which were edited",
+			osc:    `<osmChange><modify><relation id="301" version="2"/></modify></osmChange>`,
+			expIds: []int64{301, 400},
+		},
+		{
+			name:   "should match routes containing a deleted way",
+			osc:    `<osmChange><delete><way id="201" version="2" visible="false"/></delete></osmChange>`,
+			expIds: []int64{301},
+		},
+		{
+			name:   "should not match routes which have not changed",
+			osc:    `<osmChange><modify><node id="999" version="2" lat="51.5" lon="-0.1"/></modify></osmChange>`,
+			expIds: []int64{},
+		},
+	}
+
+	index := loadTestIndex(t)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			change, err := osm.DecodeOsmChange(strings.NewReader(tc.osc))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expIds, getRelationIds(t, index, change))
+		})
+	}
+}
+
+func TestIndex_Update(t *testing.T) {
+	index := loadTestIndex(t)
+	change, err := LoadDiffFile("testdata/sample.osc")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{301, 302}, getRelationIds(t, index, change))
+
+	//Node 106 was added to way 203, so later edits to it should be matched too
+	index.Update(change)
+	later, err := osm.DecodeOsmChange(strings.NewReader(`<osmChange><modify><node id="106" version="2" lat="55.9" lon="-3.2"/></modify></osmChange>`))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{302}, getRelationIds(t, index, later))
+}
@@ -0,0 +1,141 @@
+package replication
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+const MinutelyUrl = "https://planet.openstreetmap.org/replication/minute"
+const HourlyUrl = "https://planet.openstreetmap.org/replication/hour"
+
+// State is a replication state.txt file, which gives the sequence number of the latest diff
+type State struct {
+	SequenceNumber int64     `json:"sequenceNumber"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// ParseState reads a state.txt file. It is a Java properties file, so the colons in the timestamp are escaped
+func ParseState(r io.Reader) (State, error) {
+	var state State
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		value = strings.ReplaceAll(value, `\:`, ":")
+
+		var err error
+		switch key {
+		case "sequenceNumber":
+			state.SequenceNumber, err = strconv.ParseInt(value, 10, 64)
+		case "timestamp":
+			state.Timestamp, err = time.Parse(time.RFC3339, value)
+		}
+		if err != nil {
+			return State{}, fmt.Errorf("invalid replication state %s: %w", key, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return State{}, err
+	}
+	if state.SequenceNumber < 1 {
+		return State{}, errors.New("replication state is missing sequenceNumber")
+	}
+	return state, nil
+}
+
+// GetDiffPath returns the path of a diff, relative to the replication URL, e.g. 006/123/456.osc.gz
+func GetDiffPath(sequenceNumber int64) string {
+	s := fmt.Sprintf("%09d", sequenceNumber)
+	return fmt.Sprintf("%s/%s/%s.osc.gz", s[0:3], s[3:6], s[6:9])
+}
+
+// LoadDiffFile reads a local osmChange file, which may be gzipped like the diffs on replication servers
+func LoadDiffFile(path string) (*osm.OsmChange, error) {
+	file, err := os.Open(path) // #nosec G304 -- File inclusion via variable is intentional
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if !strings.HasSuffix(path, ".gz") {
+		return osm.DecodeOsmChange(file)
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return osm.DecodeOsmChange(reader)
+}
+
+// Client loads replication states and diffs from a replication server such as planet.openstreetmap.org
+type Client struct {
+	httpClient *http.Client
+	baseUrl    string
+	userAgent  string
+}
+
+func NewClient(baseUrl string, userAgent string) *Client {
+	return &Client{httpClient: &http.Client{Timeout: 30 * time.Second}, baseUrl: strings.TrimSuffix(baseUrl, "/"), userAgent: userAgent}
+}
+
+// GetState returns the state of the latest diff
+func (c *Client) GetState(ctx context.Context) (State, error) {
+	body, err := c.get(ctx, "state.txt")
+	if err != nil {
+		return State{}, err
+	}
+	defer body.Close()
+	return ParseState(body)
+}
+
+// GetDiff loads and decodes a diff
+func (c *Client) GetDiff(ctx context.Context, sequenceNumber int64) (*osm.OsmChange, error) {
+	body, err := c.get(ctx, GetDiffPath(sequenceNumber))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	reader, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return osm.DecodeOsmChange(reader)
+}
+
+func (c *Client) get(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseUrl+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	response, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		_ = response.Body.Close()
+		return nil, osm.HttpStatusError{StatusCode: response.StatusCode}
+	}
+	return response.Body, nil
+}
@@ -0,0 +1,98 @@
+package replication
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseState(t *testing.T) {
+	testcases := []struct {
+		name    string
+		input   string
+		checkFn func(t *testing.T, state State, err error)
+	}{
+		{
+			name:  "should parse state file",
+			input: "#Sat Oct 17 10:00:02 UTC 2026\nsequenceNumber=6789012\ntimestamp=2026-10-17T10\\:00\\:00Z\n",
+			checkFn: func(t *testing.T, state State, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, int64(6789012), state.SequenceNumber)
+				assert.Equal(t, time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC), state.Timestamp)
+			},
+		},
+		{
+			name:  "should fail without sequence number",
+			input: "timestamp=2026-10-17T10\\:00\\:00Z\n",
+			checkFn: func(t *testing.T, state State, err error) {
+				assert.EqualError(t, err, "replication state is missing sequenceNumber")
+			},
+		},
+		{
+			name:  "should fail with invalid sequence number",
+			input: "sequenceNumber=abc\n",
+			checkFn: func(t *testing.T, state State, err error) {
+				assert.ErrorContains(t, err, "invalid replication state sequenceNumber")
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := ParseState(strings.NewReader(tc.input))
+			tc.checkFn(t, state, err)
+		})
+	}
+}
+
+func TestGetDiffPath(t *testing.T) {
+	assert.Equal(t, "006/789/012.osc.gz", GetDiffPath(6789012))
+	assert.Equal(t, "000/000/001.osc.gz", GetDiffPath(1))
+}
+
+func TestCheckDiffs(t *testing.T) {
+	osc, err := os.ReadFile("testdata/sample.osc")
+	require.NoError(t, err)
+
+	requested := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/state.txt":
+			_, _ = w.Write([]byte("sequenceNumber=101\ntimestamp=2026-10-17T10\\:01\\:00Z\n"))
+		case "/000/000/101.osc.gz":
+			_, _ = w.Write(gzipString(t, string(osc)))
+		default:
+			//Diff 100 contains nothing relevant
+			_, _ = w.Write(gzipString(t, "<osmChange></osmChange>"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test")
+	checkEvents, last, err := CheckDiffs(context.Background(), client, loadTestIndex(t), nil, 99)
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), last)
+	assert.Equal(t, []string{"/state.txt", "/000/000/100.osc.gz", "/000/000/101.osc.gz"}, requested)
+	require.Len(t, checkEvents, 2)
+	assert.Equal(t, int64(301), checkEvents[0].RelationID)
+	assert.Equal(t, int64(302), checkEvents[1].RelationID)
+}
+
+func gzipString(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
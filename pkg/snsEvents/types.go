@@ -7,4 +7,8 @@ type InvalidRelationEvent struct {
 	RelationURL      string                       `json:"relationURL"`
 	RelationName     string                       `json:"name"`
 	ValidationErrors []validation.ValidationError `json:"validationErrors"`
+	// Culprits are the changesets which introduced the validation errors, newest first
+	Culprits []validation.Culprit `json:"culprits,omitempty"`
+	// Cassette is the S3 URI of the OSM responses recorded during validation, which can be replayed to reproduce it
+	Cassette string `json:"cassette,omitempty"`
 }
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileStore is a Store which keeps each result in a JSON file named after the relation ID, for running locally
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Get(_ context.Context, relationId int64) (Result, bool, error) {
+	bytes, err := os.ReadFile(s.getPath(relationId))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Result{}, false, nil
+	}
+	if err != nil {
+		return Result{}, false, err
+	}
+
+	var result Result
+	err = json.Unmarshal(bytes, &result)
+	if err != nil {
+		return Result{}, false, err
+	}
+	return result, true, nil
+}
+
+func (s *FileStore) Put(_ context.Context, relationId int64, result Result) error {
+	bytes, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.getPath(relationId), bytes, 0o600)
+}
+
+func (s *FileStore) getPath(relationId int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.json", relationId))
+}
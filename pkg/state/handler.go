@@ -0,0 +1,31 @@
+package state
+
+import (
+	"github.com/ockendenjo/handler"
+)
+
+// GetPreviousResult loads the result of the last validation, if state is enabled. The relation can still be validated
+// if this fails, so errors are only logged
+func GetPreviousResult(ctx *handler.Context, store Store, relationId int64) (Result, bool) {
+	if store == nil {
+		return Result{}, false
+	}
+	result, found, err := store.Get(ctx, relationId)
+	if err != nil {
+		ctx.GetLogger().Error("failed to load previous result", "error", err.Error())
+		return Result{}, false
+	}
+	return result, found
+}
+
+// SaveResult saves the result of a validation, if state is enabled. Errors are only logged, as the relation will be
+// validated again next time
+func SaveResult(ctx *handler.Context, store Store, relationId int64, result Result) {
+	if store == nil {
+		return
+	}
+	err := store.Put(ctx, relationId, result)
+	if err != nil {
+		ctx.GetLogger().Error("failed to save result", "error", err.Error())
+	}
+}
@@ -0,0 +1,69 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/ockendenjo/osm-pt-validator/pkg/util"
+)
+
+// S3Store is a Store which keeps each result in an S3 object, shared by every invocation of the lambdas
+type S3Store struct {
+	getObject  util.GetObjectApi
+	putObject  util.PutObjectApi
+	bucketName string
+}
+
+func NewS3Store(getObject util.GetObjectApi, putObject util.PutObjectApi, bucketName string) *S3Store {
+	return &S3Store{getObject: getObject, putObject: putObject, bucketName: bucketName}
+}
+
+func (s *S3Store) Get(ctx context.Context, relationId int64) (Result, bool, error) {
+	output, err := s.getObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucketName,
+		Key:    aws.String(getKey(relationId)),
+	})
+	if _, notFound := errors.AsType[*s3Types.NoSuchKey](err); notFound {
+		return Result{}, false, nil
+	}
+	if err != nil {
+		return Result{}, false, err
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return Result{}, false, err
+	}
+	var result Result
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return Result{}, false, err
+	}
+	return result, true, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, relationId int64, result Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = s.putObject(ctx, &s3.PutObjectInput{
+		Bucket:      &s.bucketName,
+		Key:         aws.String(getKey(relationId)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func getKey(relationId int64) string {
+	return fmt.Sprintf("state/%d.json", relationId)
+}
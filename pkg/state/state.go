@@ -0,0 +1,82 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/ockendenjo/osm-pt-validator/pkg/validation"
+)
+
+// Store saves the result of the last validation of each relation, so that relations which have not changed since do
+// not need to be validated again
+type Store interface {
+	Get(ctx context.Context, relationId int64) (Result, bool, error)
+	Put(ctx context.Context, relationId int64, result Result) error
+}
+
+// Result is the outcome of validating a relation, with the fingerprint of what was validated
+type Result struct {
+	Fingerprint      Fingerprint                  `json:"fingerprint"`
+	ValidationErrors []validation.ValidationError `json:"validationErrors"`
+	Culprits         []validation.Culprit         `json:"culprits,omitempty"`
+	Cassette         string                       `json:"cassette,omitempty"`
+	ValidatedAt      time.Time                    `json:"validatedAt"`
+}
+
+// Fingerprint identifies the inputs to a validation. If the relation, any of its members, the config or the checks the
+// validator makes change, the fingerprint changes too
+type Fingerprint struct {
+	RelationVersion int32 `json:"relationVersion"`
+	// MemberVersions is keyed by element type and ID, e.g. way/123
+	MemberVersions map[string]int32 `json:"memberVersions"`
+	ConfigHash     string           `json:"configHash"`
+	// ValidatorVersion is validation.Version when the relation was validated
+	ValidatorVersion int `json:"validatorVersion"`
+}
+
+// NewFingerprint returns the fingerprint of a relation and the ways, nodes and relations loaded with it
+func NewFingerprint(full osm.FullRelation, config validation.Config) (Fingerprint, error) {
+	configHash, err := hashConfig(config)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	memberVersions := map[string]int32{}
+	for _, way := range full.Ways {
+		memberVersions[fmt.Sprintf("way/%d", way.ID)] = way.Version
+	}
+	for _, node := range full.Nodes {
+		memberVersions[fmt.Sprintf("node/%d", node.ID)] = node.Version
+	}
+	for _, relation := range full.Relations {
+		memberVersions[fmt.Sprintf("relation/%d", relation.ID)] = relation.Version
+	}
+	return Fingerprint{
+		RelationVersion:  full.Relation.Version,
+		MemberVersions:   memberVersions,
+		ConfigHash:       configHash,
+		ValidatorVersion: validation.Version,
+	}, nil
+}
+
+func (f Fingerprint) Equal(other Fingerprint) bool {
+	return f.RelationVersion == other.RelationVersion &&
+		f.ConfigHash == other.ConfigHash &&
+		f.ValidatorVersion == other.ValidatorVersion &&
+		maps.Equal(f.MemberVersions, other.MemberVersions)
+}
+
+func hashConfig(config validation.Config) (string, error) {
+	bytes, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
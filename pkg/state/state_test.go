@@ -0,0 +1,177 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/ockendenjo/handler"
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/ockendenjo/osm-pt-validator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFullRelation() osm.FullRelation {
+	return osm.FullRelation{
+		Relation: osm.Relation{ID: 301, Version: 12},
+		Ways:     map[int64]osm.Way{201: {ID: 201, Version: 7}},
+		Nodes:    map[int64]osm.Node{101: {ID: 101, Version: 3}, 102: {ID: 102, Version: 1}},
+	}
+}
+
+func TestNewFingerprint(t *testing.T) {
+	config := validation.DefaultConfig()
+	expected, err := NewFingerprint(testFullRelation(), config)
+	require.NoError(t, err)
+
+	testcases := []struct {
+		name     string
+		modifyFn func(full *osm.FullRelation, config *validation.Config)
+		expEqual bool
+	}{
+		{
+			name:     "should be equal if nothing changed",
+			modifyFn: func(full *osm.FullRelation, config *validation.Config) {},
+			expEqual: true,
+		},
+		{
+			name: "should change if the relation was edited",
+			modifyFn: func(full *osm.FullRelation, config *validation.Config) {
+				full.Relation.Version = 13
+			},
+		},
+		{
+			name: "should change if a member was edited",
+			modifyFn: func(full *osm.FullRelation, config *validation.Config) {
+				full.Nodes[102] = osm.Node{ID: 102, Version: 2}
+			},
+		},
+		{
+			name: "should change if a member was removed",
+			modifyFn: func(full *osm.FullRelation, config *validation.Config) {
+				delete(full.Ways, 201)
+			},
+		},
+		{
+			name: "should change if a member relation was loaded",
+			modifyFn: func(full *osm.FullRelation, config *validation.Config) {
+				full.Relations = map[int64]osm.Relation{401: {ID: 401, Version: 2}}
+			},
+		},
+		{
+			name: "should change if the config changed",
+			modifyFn: func(full *osm.FullRelation, config *validation.Config) {
+				config.MinimumNodeMembers = 2
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			full := testFullRelation()
+			config := validation.DefaultConfig()
+			tc.modifyFn(&full, &config)
+
+			fingerprint, err := NewFingerprint(full, config)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expEqual, expected.Equal(fingerprint))
+		})
+	}
+}
+
+func TestFingerprint_validatorVersion(t *testing.T) {
+	fingerprint, err := NewFingerprint(testFullRelation(), validation.DefaultConfig())
+	require.NoError(t, err)
+	assert.Equal(t, validation.Version, fingerprint.ValidatorVersion)
+
+	//Results saved before the validator version was added have version 0
+	older := fingerprint
+	older.ValidatorVersion = 0
+	assert.False(t, fingerprint.Equal(older))
+}
+
+func TestStores(t *testing.T) {
+	objects := map[string][]byte{}
+	getObject := func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		data, found := objects[*params.Key]
+		if !found {
+			return nil, &s3Types.NoSuchKey{}
+		}
+		return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+	}
+	putObject := func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		data, err := io.ReadAll(params.Body)
+		if err != nil {
+			return nil, err
+		}
+		objects[*params.Key] = data
+		return &s3.PutObjectOutput{}, nil
+	}
+
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	testcases := []struct {
+		name  string
+		store Store
+	}{
+		{
+			name:  "file",
+			store: fileStore,
+		},
+		{
+			name:  "S3",
+			store: NewS3Store(getObject, putObject, "bucket"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, found, err := tc.store.Get(ctx, 301)
+			require.NoError(t, err)
+			assert.False(t, found)
+
+			fingerprint, err := NewFingerprint(testFullRelation(), validation.DefaultConfig())
+			require.NoError(t, err)
+			result := Result{
+				Fingerprint:      fingerprint,
+				ValidationErrors: []validation.ValidationError{{URL: "https://www.openstreetmap.org/way/201", Message: "ways are incorrectly ordered"}},
+				ValidatedAt:      time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC),
+			}
+			require.NoError(t, tc.store.Put(ctx, 301, result))
+
+			saved, found, err := tc.store.Get(ctx, 301)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, result, saved)
+			assert.True(t, fingerprint.Equal(saved.Fingerprint))
+		})
+	}
+	assert.Contains(t, objects, "state/301.json")
+}
+
+func TestSaveResult(t *testing.T) {
+	ctx := handler.Get(t.Context())
+
+	//State is disabled without a store
+	SaveResult(ctx, nil, 301, Result{})
+	_, found := GetPreviousResult(ctx, nil, 301)
+	assert.False(t, found)
+
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	_, found = GetPreviousResult(ctx, store, 301)
+	assert.False(t, found)
+
+	validatedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	SaveResult(ctx, store, 301, Result{ValidatedAt: validatedAt})
+	result, found := GetPreviousResult(ctx, store, 301)
+	assert.True(t, found)
+	assert.Equal(t, validatedAt, result.ValidatedAt)
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ockendenjo/handler"
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+type PutObjectApi func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+type GetObjectApi func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+
+// CassetteUploader saves the OSM responses recorded while validating a relation to S3, and returns the S3 URI
+type CassetteUploader func(ctx context.Context, relationId int64, cassette *osm.Cassette) (string, error)
+
+func NewCassetteUploader(putObject PutObjectApi, bucketName string) CassetteUploader {
+	return func(ctx context.Context, relationId int64, cassette *osm.Cassette) (string, error) {
+		data, err := cassette.Marshal()
+		if err != nil {
+			return "", err
+		}
+
+		key := fmt.Sprintf("cassettes/%d/%s.json", relationId, time.Now().UTC().Format("20060102T150405Z"))
+		_, err = putObject(ctx, &s3.PutObjectInput{
+			Bucket:      &bucketName,
+			Key:         &key,
+			Body:        bytes.NewReader(data),
+			ContentType: aws.String("application/json"),
+		})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("s3://%s/%s", bucketName, key), nil
+	}
+}
+
+// SaveCassette uploads the recorded OSM responses, if recording is enabled. Failing to save the cassette should not
+// stop the invalid relation being reported, so errors are only logged
+func SaveCassette(ctx *handler.Context, upload CassetteUploader, relationId int64, cassette *osm.Cassette) string {
+	if upload == nil {
+		return ""
+	}
+	uri, err := upload(ctx, relationId, cassette)
+	if err != nil {
+		ctx.GetLogger().Error("failed to save cassette", "error", err.Error())
+		return ""
+	}
+	return uri
+}
+
+func LogCacheStats(logger *handler.Logger, cache *osm.MemoryCache) {
+	stats := cache.Stats()
+	logger.Info("OSM cache stats", "hits", stats.Hits, "misses", stats.Misses, "evictions", stats.Evictions, "entries", stats.Entries)
+}
@@ -2,10 +2,13 @@ package util
 
 import (
 	"context"
+	"io"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,3 +57,24 @@ func TestNewSQSBatchSender(t *testing.T) {
 		})
 	}
 }
+
+func TestNewCassetteUploader(t *testing.T) {
+	cassette := osm.NewCassette()
+
+	var input *s3.PutObjectInput
+	putObjectApi := func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		input = params
+		return &s3.PutObjectOutput{}, nil
+	}
+	upload := NewCassetteUploader(putObjectApi, "bucketName")
+
+	uri, err := upload(context.Background(), 301, cassette)
+	assert.NoError(t, err)
+	assert.Equal(t, "bucketName", *input.Bucket)
+	assert.Regexp(t, `^cassettes/301/\d{8}T\d{6}Z\.json$`, *input.Key)
+	assert.Equal(t, "s3://bucketName/"+*input.Key, uri)
+
+	body, err := io.ReadAll(input.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"interactions": []}`, string(body))
+}
@@ -0,0 +1,150 @@
+package validation
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+// maxBlameChangesets limits how far back Blame walks, as the relation is validated again before each changeset
+const maxBlameChangesets = 10
+
+// Culprit is a changeset which introduced validation errors to a relation
+type Culprit struct {
+	Changeset        int64             `json:"changeset"`
+	URL              string            `json:"url"`
+	User             string            `json:"user,omitempty"`
+	Timestamp        time.Time         `json:"timestamp"`
+	ValidationErrors []ValidationError `json:"validationErrors"`
+}
+
+// Blame walks back through the changesets which edited a route relation or its failing members, validating the
+// relation as it was before each one until it validates cleanly. Each validation error is blamed on the newest
+// changeset without which the error goes away. Errors which are older than maxBlameChangesets are not blamed
+func (v *Validator) Blame(ctx context.Context, history *osm.History, relation osm.Relation, validationErrors []ValidationError) ([]Culprit, error) {
+	edits, err := getEdits(ctx, history, relation, validationErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	//Errors are compared without their detail, as e.g. the size of a gap may have changed since the error was introduced
+	remaining := map[ValidationError]bool{}
+	for _, ve := range validationErrors {
+		remaining[ve.withoutDetail()] = true
+	}
+
+	culprits := []Culprit{}
+	for _, edit := range edits[:min(len(edits), maxBlameChangesets)] {
+		before, err := v.validateAsOf(ctx, history.AsOf(osm.AsOf{Changeset: edit.Changeset - 1}), relation.ID)
+		if err != nil && !osm.IsDeleted(err) {
+			return culprits, err
+		}
+
+		//If the relation did not exist before the changeset, the changeset introduced all the remaining errors
+		culprit := edit
+		for _, ve := range validationErrors {
+			key := ve.withoutDetail()
+			if remaining[key] && !slices.ContainsFunc(before, func(b ValidationError) bool { return b.withoutDetail() == key }) {
+				culprit.ValidationErrors = append(culprit.ValidationErrors, ve)
+			}
+		}
+		for _, ve := range culprit.ValidationErrors {
+			delete(remaining, ve.withoutDetail())
+		}
+		if len(culprit.ValidationErrors) > 0 {
+			culprits = append(culprits, culprit)
+		}
+		if len(remaining) == 0 {
+			break
+		}
+	}
+	return culprits, nil
+}
+
+func (v *Validator) validateAsOf(ctx context.Context, provider osm.Provider, relationId int64) ([]ValidationError, error) {
+	full, err := provider.GetRelationFull(ctx, relationId)
+	if err != nil {
+		return nil, err
+	}
+	return NewValidator(v.config, provider).RouteRelation(ctx, full.Relation)
+}
+
+// getEdits returns the changesets which edited the relation or the elements referenced by its validation errors,
+// newest first
+func getEdits(ctx context.Context, history *osm.History, relation osm.Relation, validationErrors []ValidationError) ([]Culprit, error) {
+	edits := map[int64]Culprit{}
+	addEdit := func(changeset int64, timestamp time.Time, user string) {
+		if _, found := edits[changeset]; !found {
+			edits[changeset] = Culprit{Changeset: changeset, URL: getChangesetURL(changeset), User: user, Timestamp: timestamp}
+		}
+	}
+
+	relVersions, err := history.GetRelationHistory(ctx, relation.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rv := range relVersions {
+		addEdit(rv.Changeset, rv.Timestamp, rv.User)
+	}
+
+	for _, ve := range validationErrors {
+		elemType, id, ok := parseElementURL(ve.URL)
+		if !ok {
+			continue
+		}
+		switch elemType {
+		case "way":
+			versions, err := history.GetWayHistory(ctx, id)
+			if err != nil && !osm.IsDeleted(err) {
+				return nil, err
+			}
+			for _, wv := range versions {
+				addEdit(wv.Changeset, wv.Timestamp, wv.User)
+			}
+		case "node":
+			versions, err := history.GetNodeHistory(ctx, id)
+			if err != nil && !osm.IsDeleted(err) {
+				return nil, err
+			}
+			for _, nv := range versions {
+				addEdit(nv.Changeset, nv.Timestamp, nv.User)
+			}
+		}
+	}
+
+	sorted := []Culprit{}
+	for _, edit := range edits {
+		sorted = append(sorted, edit)
+	}
+	slices.SortFunc(sorted, func(a, b Culprit) int {
+		return cmp.Compare(b.Changeset, a.Changeset)
+	})
+	return sorted, nil
+}
+
+func getChangesetURL(changeset int64) string {
+	return fmt.Sprintf("https://www.openstreetmap.org/changeset/%d", changeset)
+}
+
+// parseElementURL returns the type and ID of the element an OSM website URL links to
+func parseElementURL(url string) (string, int64, bool) {
+	path, found := strings.CutPrefix(url, "https://www.openstreetmap.org/")
+	if !found {
+		return "", 0, false
+	}
+	elemType, idStr, found := strings.Cut(path, "/")
+	if !found {
+		return "", 0, false
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return elemType, id, true
+}
@@ -0,0 +1,136 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blameHistory has every version of the elements in a route. The ref tag was removed from the route in changeset
+// 200, and way 202 was moved away from way 201 in changeset 300 and then moved slightly further in changeset 400
+const blameHistory = `[
+	{"type": "relation", "id": 301, "version": 1, "timestamp": "2021-01-01T00:00:00Z", "changeset": 100, "user": "Mapper A",
+		"members": [{"type": "node", "ref": 101, "role": "stop"}, {"type": "way", "ref": 201, "role": ""}, {"type": "way", "ref": 202, "role": ""}],
+		"tags": {"type": "route", "route": "bus", "public_transport:version": "2", "from": "A", "to": "B", "name": "1: A => B", "operator": "Buses", "ref": "1"}},
+	{"type": "relation", "id": 301, "version": 2, "timestamp": "2022-01-01T00:00:00Z", "changeset": 200, "user": "Mapper B",
+		"members": [{"type": "node", "ref": 101, "role": "stop"}, {"type": "way", "ref": 201, "role": ""}, {"type": "way", "ref": 202, "role": ""}],
+		"tags": {"type": "route", "route": "bus", "public_transport:version": "2", "from": "A", "to": "B", "name": "1: A => B", "operator": "Buses"}},
+	{"type": "way", "id": 201, "version": 1, "timestamp": "2021-01-01T00:00:00Z", "changeset": 100, "user": "Mapper A",
+		"nodes": [101, 102], "tags": {"highway": "tertiary"}},
+	{"type": "way", "id": 202, "version": 1, "timestamp": "2021-01-01T00:00:00Z", "changeset": 100, "user": "Mapper A",
+		"nodes": [102, 103], "tags": {"highway": "tertiary"}},
+	{"type": "way", "id": 202, "version": 2, "timestamp": "2023-01-01T00:00:00Z", "changeset": 300, "user": "Mapper C",
+		"nodes": [104, 103], "tags": {"highway": "tertiary"}},
+	{"type": "way", "id": 202, "version": 3, "timestamp": "2024-01-01T00:00:00Z", "changeset": 400, "user": "Mapper D",
+		"nodes": [104, 105], "tags": {"highway": "tertiary"}},
+	{"type": "node", "id": 101, "version": 1, "timestamp": "2021-01-01T00:00:00Z", "changeset": 100, "user": "Mapper A",
+		"lat": 55.9214, "lon": -3.2894, "tags": {"public_transport": "stop_position", "bus": "yes", "name": "Stop"}},
+	{"type": "node", "id": 102, "version": 1, "timestamp": "2021-01-01T00:00:00Z", "changeset": 100, "user": "Mapper A",
+		"lat": 55.9220, "lon": -3.2880},
+	{"type": "node", "id": 103, "version": 1, "timestamp": "2021-01-01T00:00:00Z", "changeset": 100, "user": "Mapper A",
+		"lat": 55.9225, "lon": -3.2866},
+	{"type": "node", "id": 104, "version": 1, "timestamp": "2023-01-01T00:00:00Z", "changeset": 300, "user": "Mapper C",
+		"lat": 55.9230, "lon": -3.2870},
+	{"type": "node", "id": 105, "version": 1, "timestamp": "2024-01-01T00:00:00Z", "changeset": 400, "user": "Mapper D",
+		"lat": 55.9228, "lon": -3.2860}
+]`
+
+func TestValidator_Blame(t *testing.T) {
+	server := newHistoryServer(t, blameHistory)
+	client := osm.NewClient("test").WithBaseUrl(server.URL).WithRetryPolicy(osm.NoRetryPolicy())
+	ctx := context.Background()
+
+	relation, err := client.GetRelation(ctx, 301)
+	require.NoError(t, err)
+	validator := DefaultValidator(client)
+	validationErrors, err := validator.RouteRelation(ctx, relation)
+	require.NoError(t, err)
+	require.NotEmpty(t, validationErrors)
+
+	culprits, err := validator.Blame(ctx, client.History(), relation, validationErrors)
+	require.NoError(t, err)
+	require.Len(t, culprits, 2)
+
+	assert.Equal(t, int64(300), culprits[0].Changeset)
+	assert.Equal(t, "https://www.openstreetmap.org/changeset/300", culprits[0].URL)
+	assert.Equal(t, "Mapper C", culprits[0].User)
+	//The gap was introduced in changeset 300, though changeset 400 changed its size
+	assertContainsValidationError(t, culprits[0].ValidationErrors, ValidationError{
+		URL:     "https://www.openstreetmap.org/way/202",
+		Message: "ways are incorrectly ordered",
+		Detail:  "gap of 127 m from way 201",
+	})
+
+	assert.Equal(t, int64(200), culprits[1].Changeset)
+	assert.Equal(t, "Mapper B", culprits[1].User)
+	assert.Equal(t, []ValidationError{{URL: "https://www.openstreetmap.org/relation/301", Message: "missing tag 'ref'"}}, culprits[1].ValidationErrors)
+}
+
+func Test_parseElementURL(t *testing.T) {
+	elemType, id, ok := parseElementURL("https://www.openstreetmap.org/way/202")
+	assert.True(t, ok)
+	assert.Equal(t, "way", elemType)
+	assert.Equal(t, int64(202), id)
+
+	_, _, ok = parseElementURL("")
+	assert.False(t, ok)
+	_, _, ok = parseElementURL("https://www.openstreetmap.org/way/abc")
+	assert.False(t, ok)
+}
+
+// newHistoryServer serves the current versions, multi-fetches and histories of elements like the OSM API
+func newHistoryServer(t *testing.T, history string) *httptest.Server {
+	var elements []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(history), &elements))
+
+	versions := map[string][]map[string]any{}
+	for _, e := range elements {
+		key := fmt.Sprintf("%s/%v", e["type"], e["id"])
+		versions[key] = append(versions[key], e)
+	}
+	latest := func(key string) (map[string]any, bool) {
+		v, found := versions[key]
+		if !found {
+			return nil, false
+		}
+		return v[len(v)-1], true
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, ".json"), "/")
+		found := []map[string]any{}
+
+		switch {
+		case path == "ways" || path == "nodes":
+			elemType := strings.TrimSuffix(path, "s")
+			for _, id := range strings.Split(r.URL.Query().Get(path), ",") {
+				if e, ok := latest(elemType + "/" + id); ok {
+					found = append(found, e)
+				}
+			}
+		case strings.HasSuffix(path, "/history"):
+			found = versions[strings.TrimSuffix(path, "/history")]
+		default:
+			if e, ok := latest(path); ok {
+				found = append(found, e)
+			}
+		}
+
+		if len(found) < 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"elements": found})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
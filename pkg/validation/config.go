@@ -1,9 +1,17 @@
 package validation
 
+import "github.com/ockendenjo/osm-pt-validator/pkg/osm"
+
+// defaultMaxPlatformDistance is the furthest a platform can be from the route, in metres, unless the config sets it
+const defaultMaxPlatformDistance = 50
+
 type Config struct {
 	NaptanPlatformTags   bool         `json:"naptanPlatformTags"`
 	MinimumNodeMembers   int          `json:"minimumNodeMembers"`
 	MinimumRouteVariants int          `json:"minimumRouteVariants"`
+	MaxPlatformDistance  float64      `json:"maxPlatformDistance,omitempty"`
+	StopPairing          StopPairing  `json:"stopPairing,omitempty"`
+	StopAreas            bool         `json:"stopAreas,omitempty"`
 	Ignore               IgnoreConfig `json:"ignore"`
 }
 
@@ -26,6 +34,14 @@ func DefaultConfig() Config {
 	return Config{NaptanPlatformTags: true}
 }
 
+// GetMaxPlatformDistance returns the furthest a platform can be from the route, in metres
+func (c *Config) GetMaxPlatformDistance() float64 {
+	if c.MaxPlatformDistance > 0 {
+		return c.MaxPlatformDistance
+	}
+	return defaultMaxPlatformDistance
+}
+
 func (c *Config) IsWayDirectionIgnored(wayId int64) bool {
 	if c.Ignore.Ways.traversalMap == nil {
 		c.buildTraversalMap()
@@ -56,6 +72,12 @@ func (c *Config) IsNodeErrorIgnored(nodeId int64) bool {
 	return false
 }
 
+// isMemberErrorIgnored returns whether errors for a relation member are ignored. Only nodes can be ignored, as way and
+// node IDs overlap
+func (c *Config) isMemberErrorIgnored(member osm.Member) bool {
+	return member.Type == "node" && c.IsNodeErrorIgnored(member.Ref)
+}
+
 func (c *Config) buildNodeMap() {
 	m := map[int64]bool{}
 	for _, node := range c.Ignore.Nodes.Any {
@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+const earthRadius = 6371008.8
+
+// distance returns the great-circle distance between two nodes in metres
+func distance(a osm.Node, b osm.Node) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// projectOnSegment returns the distance in metres from a point to the nearest point on the segment from a to b, and how
+// far along the segment the nearest point is, from 0 at a to 1 at b. Coordinates are projected onto a plane around the
+// point, which is accurate enough over the length of a way segment
+func projectOnSegment(point osm.Node, a osm.Node, b osm.Node) (float64, float64) {
+	metresPerDegree := earthRadius * math.Pi / 180
+	scale := math.Cos(point.Lat * math.Pi / 180)
+	ax, ay := (a.Lon-point.Lon)*scale*metresPerDegree, (a.Lat-point.Lat)*metresPerDegree
+	bx, by := (b.Lon-point.Lon)*scale*metresPerDegree, (b.Lat-point.Lat)*metresPerDegree
+
+	dx, dy := bx-ax, by-ay
+	fraction := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		fraction = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSq))
+	}
+	return math.Hypot(ax+fraction*dx, ay+fraction*dy), fraction
+}
+
+func formatDistance(metres float64) string {
+	if metres < 1000 {
+		return fmt.Sprintf("%d m", int(math.Round(metres)))
+	}
+	return fmt.Sprintf("%.1f km", metres/1000)
+}
@@ -2,39 +2,49 @@ package validation
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 )
 
-func (v *Validator) validateRelationNodes(ctx context.Context, re osm.Relation) ([]ValidationError, error) {
+// validateRelationNodes checks the tags of the stop and platform nodes. The stop areas are nil unless a check needs them
+func (v *Validator) validateRelationNodes(ctx context.Context, re osm.Relation, stopAreas stopAreaMembership) ([]ValidationError, error) {
 	nodeIds := []int64{}
-	nodes := []osm.Member{}
 	validationErrors := []ValidationError{}
 
 	for _, member := range re.Members {
 		if member.Type == "node" {
 			nodeIds = append(nodeIds, member.Ref)
-			nodes = append(nodes, member)
 		}
 	}
 
-	nodesMap := v.osmClient.LoadNodes(ctx, nodeIds)
+	nodesMap, loadErrs := v.provider.LoadNodes(ctx, nodeIds)
 
-	for k, way := range nodesMap {
-		if way == nil {
-			return nil, fmt.Errorf("failed to load node %d", k)
+	for i, node := range re.Members {
+		if node.Type != "node" {
+			continue
 		}
-	}
-
-	for _, node := range nodes {
 		if v.config.IsNodeErrorIgnored(node.Ref) {
 			continue
 		}
 
+		if err, found := loadErrs[node.Ref]; found {
+			ve, err := memberLoadError(node, err)
+			if err != nil {
+				return nil, err
+			}
+			validationErrors = append(validationErrors, ve)
+			continue
+		}
+
 		nodeObj := nodesMap[node.Ref]
 		if node.RoleIsPlatform() {
-			validationErrors = append(validationErrors, validatePlatformNode(nodeObj, v.config.NaptanPlatformTags)...)
+			//A platform can take its name from its stop area
+			areas := stopAreas[i]
+			named := len(areas) == 1 && areas[0].Tags["name"] != ""
+			validationErrors = append(validationErrors, validatePlatformNode(nodeObj, v.config.NaptanPlatformTags, named)...)
 		}
 
 		if node.RoleIsStop() {
@@ -45,7 +55,7 @@ func (v *Validator) validateRelationNodes(ctx context.Context, re osm.Relation)
 	return validationErrors, nil
 }
 
-func validatePlatformNode(node *osm.Node, checkNaptan bool) []ValidationError {
+func validatePlatformNode(node *osm.Node, checkNaptan bool, namedStopArea bool) []ValidationError {
 	validationErrors := []ValidationError{}
 
 	pt, found := node.Tags["public_transport"]
@@ -67,7 +77,7 @@ func validatePlatformNode(node *osm.Node, checkNaptan bool) []ValidationError {
 	}
 
 	_, found = node.Tags["name"]
-	if !found {
+	if !found && !namedStopArea {
 		validationErrors = append(validationErrors, ValidationError{URL: node.GetElementURL(), Message: "node is missing name tag"})
 	}
 
@@ -94,11 +104,21 @@ func validateStopNode(node *osm.Node) []ValidationError {
 		validationErrors = append(validationErrors, ValidationError{URL: node.GetElementURL(), Message: "node should have bus=yes"})
 	}
 
-	//name, found := node.Tags["name"]
-	//if !found || len(name) < 1 {
-	//TODO: Add better validation to check if stop is part of public_transport=stop_area
-	//validationErrors = append(validationErrors, ValidationError{URL: node.GetElementURL(), Message: "node is missing name tag"})
-	//}
+	//Don't require the name tag - stop positions take their name from the public_transport=stop_area (see validateStopAreas)
 
 	return validationErrors
 }
+
+// memberLoadError converts a failure to load a member into a validation error if the member has been deleted. Any
+// other error is returned, as the relation cannot be validated
+func memberLoadError(member osm.Member, err error) (ValidationError, error) {
+	if hse, ok := errors.AsType[osm.HttpStatusError](err); ok {
+		switch hse.StatusCode {
+		case http.StatusGone:
+			return ValidationError{URL: member.GetElementURL(), Message: fmt.Sprintf("member %s %d has been deleted", member.Type, member.Ref)}, nil
+		case http.StatusNotFound:
+			return ValidationError{URL: member.GetElementURL(), Message: fmt.Sprintf("member %s %d does not exist", member.Type, member.Ref)}, nil
+		}
+	}
+	return ValidationError{}, fmt.Errorf("failed to load %s %d: %w", member.Type, member.Ref, err)
+}
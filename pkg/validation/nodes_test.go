@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateRelationNodes_platformName(t *testing.T) {
+	testcases := []struct {
+		name      string
+		platform  int64
+		stopAreas bool
+		expError  bool
+	}{
+		{
+			name:      "platform without a name",
+			platform:  146,
+			stopAreas: true,
+			expError:  true,
+		},
+		{
+			name:      "platform named by its stop area",
+			platform:  149,
+			stopAreas: true,
+			expError:  false,
+		},
+		{
+			name:      "platform in more than one stop area",
+			platform:  148,
+			stopAreas: true,
+			expError:  true,
+		},
+		{
+			name:      "should not use stop areas unless they are checked",
+			platform:  149,
+			stopAreas: false,
+			expError:  true,
+		},
+	}
+
+	store, err := loadTestStore()
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.StopAreas = tc.stopAreas
+			validator := NewValidator(config, store)
+			relation := osm.Relation{Members: []osm.Member{{Type: "node", Ref: tc.platform, Role: osm.RolePlatform}}}
+
+			ctx := context.Background()
+			var stopAreas stopAreaMembership
+			if validator.needsStopAreas() {
+				stopAreas, err = validator.loadStopAreas(ctx, relation)
+				require.NoError(t, err)
+			}
+			validationErrors, err := validator.validateRelationNodes(ctx, relation, stopAreas)
+			require.NoError(t, err)
+
+			nameError := ValidationError{URL: relation.Members[0].GetElementURL(), Message: "node is missing name tag"}
+			if tc.expError {
+				assert.Contains(t, validationErrors, nameError)
+			} else {
+				assert.NotContains(t, validationErrors, nameError)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package validation
+
+import (
+	"context"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+// routePosition is where a platform is nearest to a segment of the route
+type routePosition struct {
+	distance float64
+	// along is the distance along the route in metres
+	along float64
+}
+
+// validatePlatformOrder projects each platform onto the route, and checks that the platforms are in the order the route
+// passes them and close enough to it. Platforms which are near the route at several points, e.g. on routes which return
+// along the same road, are matched to the first point after the previous platform
+func (v *Validator) validatePlatformOrder(ctx context.Context, wayDirects []wayDirection, re osm.Relation) ([]ValidationError, error) {
+	platforms := []osm.Member{}
+	for _, member := range re.Members {
+		if member.RoleIsPlatform() && (member.Type == "node" || member.Type == "way") {
+			platforms = append(platforms, member)
+		}
+	}
+	if len(platforms) < 1 {
+		return nil, nil
+	}
+
+	route, err := v.loadRouteGeometry(ctx, wayDirects)
+	if err != nil || len(route) < 2 {
+		return nil, err
+	}
+
+	maxDistance := v.config.GetMaxPlatformDistance()
+	validationErrors := []ValidationError{}
+	lastAlong := -1.0
+	for _, platform := range platforms {
+		point, found, err := v.getPlatformPoint(ctx, platform)
+		if err != nil {
+			return nil, err
+		}
+		if !found || v.config.isMemberErrorIgnored(platform) {
+			continue
+		}
+
+		positions := projectOnRoute(point, route)
+		nearest := positions[0]
+		var next *routePosition
+		for i, position := range positions {
+			if position.distance < nearest.distance {
+				nearest = position
+			}
+			if position.distance <= maxDistance && position.along >= lastAlong && (next == nil || position.along < next.along) {
+				next = &positions[i]
+			}
+		}
+
+		switch {
+		case nearest.distance > maxDistance:
+			ve := ValidationError{URL: platform.GetElementURL(), Message: "platform is too far from the route", Detail: formatDistance(nearest.distance)}
+			validationErrors = append(validationErrors, ve)
+		case next == nil:
+			validationErrors = append(validationErrors, ValidationError{URL: platform.GetElementURL(), Message: "platform is incorrectly ordered"})
+		default:
+			lastAlong = next.along
+		}
+	}
+	return validationErrors, nil
+}
+
+// loadRouteGeometry returns the nodes of the route in order. Nodes which can't be loaded are left out
+func (v *Validator) loadRouteGeometry(ctx context.Context, wayDirects []wayDirection) ([]osm.Node, error) {
+	nodeIds := getAllNodesInOrder(wayDirects)
+	nodesMap, loadErrs := v.provider.LoadNodes(ctx, nodeIds)
+	for _, err := range loadErrs {
+		if !osm.IsDeleted(err) {
+			return nil, err
+		}
+	}
+
+	route := []osm.Node{}
+	for i, nid := range nodeIds {
+		node, found := nodesMap[nid]
+		if !found || (i > 0 && nid == nodeIds[i-1]) {
+			continue
+		}
+		route = append(route, *node)
+	}
+	return route, nil
+}
+
+// getPlatformPoint returns the location of a platform node, or the middle of a platform way
+func (v *Validator) getPlatformPoint(ctx context.Context, platform osm.Member) (osm.Node, bool, error) {
+	nodeIds := []int64{platform.Ref}
+	if platform.Type == "way" {
+		ways, loadErrs := v.provider.LoadWays(ctx, []int64{platform.Ref})
+		if err, found := loadErrs[platform.Ref]; found {
+			_, err = memberLoadError(platform, err)
+			return osm.Node{}, false, err
+		}
+		nodeIds = ways[platform.Ref].Nodes
+	}
+
+	nodes, loadErrs := v.provider.LoadNodes(ctx, nodeIds)
+	for _, err := range loadErrs {
+		if !osm.IsDeleted(err) {
+			return osm.Node{}, false, err
+		}
+	}
+	if len(nodes) < 1 {
+		return osm.Node{}, false, nil
+	}
+
+	point := osm.Node{}
+	for _, node := range nodes {
+		point.Lat += node.Lat / float64(len(nodes))
+		point.Lon += node.Lon / float64(len(nodes))
+	}
+	return point, true, nil
+}
+
+// projectOnRoute returns the nearest point on each segment of the route to a point
+func projectOnRoute(point osm.Node, route []osm.Node) []routePosition {
+	positions := []routePosition{}
+	along := 0.0
+	for i := 1; i < len(route); i++ {
+		a, b := route[i-1], route[i]
+		length := distance(a, b)
+		d, fraction := projectOnSegment(point, a, b)
+		positions = append(positions, routePosition{distance: d, along: along + fraction*length})
+		along += length
+	}
+	return positions
+}
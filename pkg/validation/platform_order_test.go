@@ -0,0 +1,81 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validatePlatformOrder(t *testing.T) {
+	testcases := []struct {
+		name      string
+		platforms []osm.Member
+		ways      []osm.Member
+		setConfig func(config *Config)
+		expected  []ValidationError
+	}{
+		{
+			name:      "platforms in correct order",
+			platforms: []osm.Member{setupPlatform("node", 131), setupPlatform("way", 19), setupPlatform("node", 132)},
+			ways:      setupWays(1, 2, 3),
+			expected:  []ValidationError{},
+		},
+		{
+			name:      "platforms in incorrect order",
+			platforms: []osm.Member{setupPlatform("node", 132), setupPlatform("node", 131)},
+			ways:      setupWays(1, 2, 3),
+			expected:  []ValidationError{{URL: "https://www.openstreetmap.org/node/131", Message: "platform is incorrectly ordered"}},
+		},
+		{
+			name:      "platforms in reverse order on reversed route",
+			platforms: []osm.Member{setupPlatform("node", 132), setupPlatform("way", 19), setupPlatform("node", 131)},
+			ways:      setupWays(3, 2, 1),
+			expected:  []ValidationError{},
+		},
+		{
+			name:      "platform passed twice on route returning along the same road",
+			platforms: []osm.Member{setupPlatform("node", 131), setupPlatform("node", 132), setupPlatform("node", 131)},
+			ways:      setupWays(1, 2, 3, 3, 2, 1),
+			expected:  []ValidationError{},
+		},
+		{
+			name:      "platform far from route",
+			platforms: []osm.Member{setupPlatform("node", 131), setupPlatform("node", 133)},
+			ways:      setupWays(1, 2, 3),
+			expected:  []ValidationError{{URL: "https://www.openstreetmap.org/node/133", Message: "platform is too far from the route", Detail: "623 m"}},
+		},
+		{
+			name:      "platform further from route than configured distance",
+			platforms: []osm.Member{setupPlatform("node", 131)},
+			ways:      setupWays(1, 2, 3),
+			setConfig: func(config *Config) {
+				config.MaxPlatformDistance = 10
+			},
+			expected: []ValidationError{{URL: "https://www.openstreetmap.org/node/131", Message: "platform is too far from the route", Detail: "19 m"}},
+		},
+	}
+
+	store, err := loadTestStore()
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DefaultConfig()
+			if tc.setConfig != nil {
+				tc.setConfig(&config)
+			}
+			validator := NewValidator(config, store)
+			relation := osm.Relation{Members: append(tc.platforms, tc.ways...)}
+
+			ctx := context.Background()
+			_, _, wayDirects, err := validator.validateWayOrder(ctx, relation)
+			require.NoError(t, err)
+			validationErrors, err := validator.validatePlatformOrder(ctx, wayDirects, relation)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, validationErrors)
+		})
+	}
+}
@@ -1,10 +1,28 @@
 package validation
 
 import (
+	"fmt"
+
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 )
 
-func (v *Validator) RouteMaster(r osm.Relation) []ValidationError {
+// routeMasterTags are the tags which should have the same value on a route master and each of its variants, by the key
+// on the route master
+var routeMasterTags = []struct {
+	masterKey  string
+	variantKey string
+}{
+	{masterKey: "ref", variantKey: "ref"},
+	{masterKey: "operator", variantKey: "operator"},
+	{masterKey: "network", variantKey: "network"},
+	{masterKey: "colour", variantKey: "colour"},
+	{masterKey: "route_master", variantKey: "route"},
+}
+
+// RouteMaster validates a route master relation. The variants are the member route relations, which are checked
+// against the route master but not validated themselves. Variants always have the route master as a parent, as they are
+// its members, so their parents aren't checked
+func (v *Validator) RouteMaster(r osm.Relation, variants []osm.Relation) []ValidationError {
 	validationErrors := []ValidationError{}
 
 	relCount := 0
@@ -23,5 +41,54 @@ func (v *Validator) RouteMaster(r osm.Relation) []ValidationError {
 
 	tagMissingErrors := checkTagsPresent(r, "name", "ref", "operator")
 	validationErrors = append(validationErrors, tagMissingErrors...)
+
+	for _, variant := range variants {
+		validationErrors = append(validationErrors, validateVariantTags(r, variant)...)
+	}
+
+	validationErrors = append(validationErrors, validateReverseVariants(variants)...)
+	return validationErrors
+}
+
+// validateVariantTags checks that a variant has the same ref, operator etc. as its route master. Tags which the route
+// master doesn't have aren't checked
+func validateVariantTags(master osm.Relation, variant osm.Relation) []ValidationError {
+	validationErrors := []ValidationError{}
+	for _, tag := range routeMasterTags {
+		expVal, found := master.Tags[tag.masterKey]
+		if !found {
+			continue
+		}
+		if variant.Tags[tag.variantKey] != expVal {
+			msg := fmt.Sprintf("tag '%s' should have value '%s' to match the route master", tag.variantKey, expVal)
+			validationErrors = append(validationErrors, ValidationError{URL: variant.GetElementURL(), Message: msg})
+		}
+	}
+	return validationErrors
+}
+
+// validateReverseVariants checks that each variant has another variant going the opposite way, with from and to
+// swapped. Circular routes, and variants without from and to tags, don't need a reverse
+func validateReverseVariants(variants []osm.Relation) []ValidationError {
+	validationErrors := []ValidationError{}
+	for _, variant := range variants {
+		from, foundFrom := variant.Tags["from"]
+		to, foundTo := variant.Tags["to"]
+		if !foundFrom || !foundTo || from == to {
+			continue
+		}
+
+		foundReverse := false
+		for _, other := range variants {
+			if other.ID != variant.ID && other.Tags["from"] == to && other.Tags["to"] == from {
+				foundReverse = true
+				break
+			}
+		}
+		if !foundReverse {
+			msg := fmt.Sprintf("route has no reverse variant from '%s' to '%s'", to, from)
+			validationErrors = append(validationErrors, ValidationError{URL: variant.GetElementURL(), Message: msg})
+		}
+	}
 	return validationErrors
 }
@@ -1,10 +1,12 @@
 package validation
 
 import (
+	"context"
 	"testing"
 
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidationRouteMasterMembers(t *testing.T) {
@@ -107,8 +109,57 @@ func TestValidationRouteMasterMembers(t *testing.T) {
 				tc.setupConfig(&c)
 			}
 			validator := NewValidator(c, nil)
-			validationErrors := validator.RouteMaster(osm.Relation{Members: tc.members, Tags: tc.tags, ID: 1234})
+			validationErrors := validator.RouteMaster(osm.Relation{Members: tc.members, Tags: tc.tags, ID: 1234}, nil)
 			tc.checkFn(t, validationErrors)
 		})
 	}
 }
+
+func TestValidationRouteMasterVariants(t *testing.T) {
+	testcases := []struct {
+		name       string
+		variantIds []int64
+		expected   []ValidationError
+	}{
+		{
+			name:       "variants which don't match the route master",
+			variantIds: []int64{602, 603},
+			expected: []ValidationError{
+				{URL: "https://www.openstreetmap.org/relation/603", Message: "tag 'operator' should have value 'BusCo' to match the route master"},
+				{URL: "https://www.openstreetmap.org/relation/603", Message: "tag 'colour' should have value '#FF0000' to match the route master"},
+				{URL: "https://www.openstreetmap.org/relation/602", Message: "route has no reverse variant from 'A' to 'B'"},
+				{URL: "https://www.openstreetmap.org/relation/603", Message: "route has no reverse variant from 'C' to 'A'"},
+			},
+		},
+		{
+			name:       "variants in reverse pairs",
+			variantIds: []int64{601, 602},
+			expected:   []ValidationError{},
+		},
+		{
+			name:       "circular variant without a reverse",
+			variantIds: []int64{604},
+			expected:   []ValidationError{},
+		},
+	}
+
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	ctx := context.Background()
+	master, err := store.GetRelation(ctx, 600)
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			variants := []osm.Relation{}
+			for _, id := range tc.variantIds {
+				variant, err := store.GetRelation(ctx, id)
+				require.NoError(t, err)
+				variants = append(variants, variant)
+			}
+
+			validationErrors := NewValidator(DefaultConfig(), store).RouteMaster(master, variants)
+			assert.Equal(t, tc.expected, validationErrors)
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 )
 
+// RouteRelation validates a route relation. Member ways and nodes are loaded through the OSM client caches, so loading
+// the relation with osm.OSMClient.GetRelationFull first means no further requests are needed
 func (v *Validator) RouteRelation(ctx context.Context, r osm.Relation) ([]ValidationError, error) {
 	ve, err := v.validationRelationElement(ctx, r)
 	return ve, err
@@ -26,18 +28,47 @@ func (v *Validator) validationRelationElement(ctx context.Context, re osm.Relati
 	memberOrderErrors := validateREMemberOrder(re)
 	allErrors = append(allErrors, memberOrderErrors...)
 
-	nodeErrors, err := v.validateRelationNodes(ctx, re)
+	var stopAreas stopAreaMembership
+	if v.needsStopAreas() {
+		var err error
+		stopAreas, err = v.loadStopAreas(ctx, re)
+		if err != nil {
+			return allErrors, err
+		}
+
+		stopAreaErrors, err := v.validateStopAreas(ctx, re, stopAreas)
+		allErrors = append(allErrors, stopAreaErrors...)
+		if err != nil {
+			return allErrors, err
+		}
+
+		pairingErrors, err := v.validateStopPairing(ctx, re, stopAreas)
+		allErrors = append(allErrors, pairingErrors...)
+		if err != nil {
+			return allErrors, err
+		}
+	}
+
+	nodeErrors, err := v.validateRelationNodes(ctx, re, stopAreas)
 	allErrors = append(allErrors, nodeErrors...)
 	if err != nil {
 		return allErrors, err
 	}
 
-	routeErrors, wayDirects, err := v.validateWayOrder(ctx, re)
+	routeErrors, splitErrors, wayDirects, err := v.validateWayOrder(ctx, re)
+	allErrors = append(allErrors, splitErrors...)
 	allErrors = append(allErrors, routeErrors...)
 
+	//The stop order can be checked whenever the ways form a valid route, even if some ways need splitting
 	if len(routeErrors) == 0 {
 		stopErrors := validateStopOrder(wayDirects, re)
 		allErrors = append(allErrors, stopErrors...)
+
+		platformErrors, err := v.validatePlatformOrder(ctx, wayDirects, re)
+		allErrors = append(allErrors, platformErrors...)
+		if err != nil {
+			return allErrors, err
+		}
 	}
 
 	if !v.validateNodeMembersCount(re) {
@@ -1,11 +1,13 @@
 package validation
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_validateRETags(t *testing.T) {
@@ -221,3 +223,48 @@ func Test_validateREMemberOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestValidator_RouteRelation_stopOrder(t *testing.T) {
+	stops := func(ids ...int64) []osm.Member {
+		members := []osm.Member{}
+		for _, id := range ids {
+			members = append(members, osm.Member{Type: "node", Ref: id, Role: osm.RoleStop})
+		}
+		return members
+	}
+	stopOrderError := ValidationError{URL: "https://www.openstreetmap.org/node/117", Message: "stop is incorrectly ordered"}
+
+	testcases := []struct {
+		name     string
+		members  []osm.Member
+		expError bool
+	}{
+		{
+			name:     "should check stop order when ways only need splitting",
+			members:  append(stops(102, 117), setupWays(12, 3, 2)...),
+			expError: true,
+		},
+		{
+			name:     "should not check stop order with oneway errors",
+			members:  append(stops(105, 117), setupWays(12, 3, 20)...),
+			expError: false,
+		},
+	}
+
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			relation := osm.Relation{Members: tc.members, Tags: map[string]string{"public_transport:version": "2"}}
+			validationErrors, err := validator.RouteRelation(context.Background(), relation)
+			require.NoError(t, err)
+			if tc.expError {
+				assert.Contains(t, validationErrors, stopOrderError)
+			} else {
+				assert.NotContains(t, validationErrors, stopOrderError)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+// SortMembers returns the members of a route relation in PTv2 order: stops and platforms in the order the route reaches
+// them, then the ways in path order, then any other members. It returns nil if the ways can't be joined into a single
+// path
+func (v *Validator) SortMembers(ctx context.Context, re osm.Relation) ([]osm.Member, error) {
+	order, err := v.SolveWayOrder(ctx, re)
+	if err != nil || order == nil {
+		return nil, err
+	}
+
+	stops := []osm.Member{}
+	others := []osm.Member{}
+	for _, member := range re.Members {
+		if member.RoleIsStop() || member.RoleIsPlatform() {
+			stops = append(stops, member)
+		} else if member.Type != "way" || member.Role != "" {
+			others = append(others, member)
+		}
+	}
+
+	positions, err := v.getStopPositions(ctx, stops, order.wayDirects)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []int{}
+	for i := range stops {
+		indexes = append(indexes, i)
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		return cmp.Compare(positions[a], positions[b])
+	})
+
+	members := []osm.Member{}
+	for _, i := range indexes {
+		members = append(members, stops[i])
+	}
+	members = append(members, order.Ways...)
+	return append(members, others...), nil
+}
+
+// getStopPositions returns the distance along the route of each stop and platform. Stops are positioned by their node,
+// using later visits for stops listed more than once, e.g. at both ends of a circular route. A platform listed straight
+// after its stop is kept with it, and other platforms are projected onto the route, so that routes with only platforms
+// can be sorted. Anything else is positioned with the member listed next to it
+func (v *Validator) getStopPositions(ctx context.Context, stops []osm.Member, wayDirects []wayDirection) ([]float64, error) {
+	nodeIds := getAllNodesInOrder(wayDirects)
+	nodesMap, loadErrs := v.provider.LoadNodes(ctx, nodeIds)
+	for _, err := range loadErrs {
+		if !osm.IsDeleted(err) {
+			return nil, err
+		}
+	}
+
+	route := []osm.Node{}
+	visits := map[int64][]float64{}
+	along := 0.0
+	for i, nid := range nodeIds {
+		node, found := nodesMap[nid]
+		if !found || (i > 0 && nid == nodeIds[i-1]) {
+			continue
+		}
+		if len(route) > 0 {
+			along += distance(route[len(route)-1], *node)
+		}
+		route = append(route, *node)
+		visits[nid] = append(visits[nid], along)
+	}
+
+	positions := make([]float64, len(stops))
+	found := make([]bool, len(stops))
+	seen := map[int64]int{}
+	for i, stop := range stops {
+		if stop.Type != "node" || !stop.RoleIsStop() || len(visits[stop.Ref]) == 0 {
+			continue
+		}
+		alongs := visits[stop.Ref]
+		positions[i] = alongs[min(seen[stop.Ref], len(alongs)-1)]
+		found[i] = true
+		seen[stop.Ref]++
+	}
+
+	for i, platform := range stops {
+		if found[i] || !platform.RoleIsPlatform() || len(route) < 2 || (i > 0 && found[i-1] && stops[i-1].RoleIsStop()) {
+			continue
+		}
+		point, ok, err := v.getPlatformPoint(ctx, platform)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		nearest := slices.MinFunc(projectOnRoute(point, route), func(a, b routePosition) int {
+			return cmp.Compare(a.distance, b.distance)
+		})
+		if nearest.distance <= v.config.GetMaxPlatformDistance() {
+			positions[i] = nearest.along
+			found[i] = true
+		}
+	}
+
+	for i := range stops {
+		if found[i] {
+			continue
+		}
+		//Prefer the stop before, as PTv2 lists each stop before its platform
+		switch {
+		case i > 0 && found[i-1]:
+			positions[i] = positions[i-1]
+		case i+1 < len(stops) && found[i+1]:
+			positions[i] = positions[i+1]
+		case i > 0:
+			positions[i] = positions[i-1]
+		}
+		found[i] = true
+	}
+	return positions, nil
+}
@@ -0,0 +1,221 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+// stopAreaMembership is the public_transport=stop_area relations of each stop and platform, by index of the route
+// relation member
+type stopAreaMembership map[int][]osm.Relation
+
+// needsStopAreas is whether any check uses the stop areas, as loading them needs a request for each stop and platform
+func (v *Validator) needsStopAreas() bool {
+	return v.config.StopAreas || v.config.StopPairing != StopPairingOff
+}
+
+// loadStopAreas loads the stop areas of the stops and platforms in a route relation
+func (v *Validator) loadStopAreas(ctx context.Context, re osm.Relation) (stopAreaMembership, error) {
+	stopAreas := stopAreaMembership{}
+	for i, member := range re.Members {
+		if !isStopOrPlatform(member) {
+			continue
+		}
+		areas, err := v.getStopAreas(ctx, member)
+		if err != nil {
+			return nil, err
+		}
+		stopAreas[i] = areas
+	}
+	return stopAreas, nil
+}
+
+// AddStopAreas adds the stop areas of a route relation's stops and platforms to the relations loaded with it, if any
+// check uses them. Results then depend on the stop area versions, so they should be added before fingerprinting
+func (v *Validator) AddStopAreas(ctx context.Context, full *osm.FullRelation) error {
+	if !v.needsStopAreas() {
+		return nil
+	}
+	stopAreas, err := v.loadStopAreas(ctx, full.Relation)
+	if err != nil {
+		return err
+	}
+	if full.Relations == nil {
+		full.Relations = map[int64]osm.Relation{}
+	}
+	for _, areas := range stopAreas {
+		for _, area := range areas {
+			full.Relations[area.ID] = area
+		}
+	}
+	return nil
+}
+
+// getStopAreas returns the public_transport=stop_area relations which a stop or platform is a member of. They are
+// cached for the life of the validator, as AddStopAreas and the checks both need them and each one is a request
+func (v *Validator) getStopAreas(ctx context.Context, member osm.Member) ([]osm.Relation, error) {
+	key := fmt.Sprintf("%s/%d", member.Type, member.Ref)
+	if stopAreas, found := v.stopAreas[key]; found {
+		return stopAreas, nil
+	}
+
+	parents, err := v.provider.GetParentRelations(ctx, member.Type, member.Ref)
+	if err != nil && !osm.IsDeleted(err) {
+		return nil, err
+	}
+
+	stopAreas := []osm.Relation{}
+	for _, parent := range parents {
+		if parent.Tags["public_transport"] == "stop_area" {
+			stopAreas = append(stopAreas, parent)
+		}
+	}
+	if v.stopAreas == nil {
+		v.stopAreas = map[string][]osm.Relation{}
+	}
+	v.stopAreas[key] = stopAreas
+	return stopAreas, nil
+}
+
+// validateStopAreas checks that each stop and platform is in exactly one stop area, that the stop areas are tagged
+// correctly and give the stops and platforms the right roles, and that the stops and platforms are named after their
+// stop area
+func (v *Validator) validateStopAreas(ctx context.Context, re osm.Relation, stopAreas stopAreaMembership) ([]ValidationError, error) {
+	if !v.config.StopAreas {
+		return nil, nil
+	}
+
+	validationErrors := []ValidationError{}
+	checked := map[int64]osm.Relation{}
+	for _, i := range slices.Sorted(maps.Keys(stopAreas)) {
+		member := re.Members[i]
+		if v.config.isMemberErrorIgnored(member) {
+			continue
+		}
+
+		areas := stopAreas[i]
+		switch len(areas) {
+		case 0:
+			msg := fmt.Sprintf("%s is not in a stop area", getStopOrPlatformName(member))
+			validationErrors = append(validationErrors, ValidationError{URL: member.GetElementURL(), Message: msg})
+			continue
+		case 1:
+		default:
+			ids := []string{}
+			for _, area := range areas {
+				ids = append(ids, fmt.Sprintf("%d", area.ID))
+			}
+			msg := fmt.Sprintf("%s is in more than one stop area (relations %s)", getStopOrPlatformName(member), strings.Join(ids, ", "))
+			validationErrors = append(validationErrors, ValidationError{URL: member.GetElementURL(), Message: msg})
+		}
+
+		for _, area := range areas {
+			checked[area.ID] = area
+			if ve := validateStopAreaRole(area, member); ve != nil {
+				validationErrors = append(validationErrors, *ve)
+			}
+		}
+
+		//A stop or platform in several stop areas has no single name to check against
+		if len(areas) == 1 {
+			ve, err := v.validateStopAreaName(ctx, areas[0], member)
+			if err != nil {
+				return nil, err
+			}
+			if ve != nil {
+				validationErrors = append(validationErrors, *ve)
+			}
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(checked)) {
+		validationErrors = append(validationErrors, validateStopAreaTags(checked[id])...)
+	}
+	return validationErrors, nil
+}
+
+func validateStopAreaTags(area osm.Relation) []ValidationError {
+	validationErrors := checkTagsPresent(area, "name")
+	if ve := checkTagValue(area, "type", "public_transport"); ve != nil {
+		validationErrors = append(validationErrors, *ve)
+	}
+	return validationErrors
+}
+
+// validateStopAreaRole checks that a stop area gives a stop position the role 'stop', and a platform the role 'platform'
+func validateStopAreaRole(area osm.Relation, member osm.Member) *ValidationError {
+	expected := osm.RolePlatform
+	if member.RoleIsStop() {
+		expected = osm.RoleStop
+	}
+
+	for _, areaMember := range area.Members {
+		if areaMember.Type != member.Type || areaMember.Ref != member.Ref {
+			continue
+		}
+		if areaMember.Role != expected {
+			msg := fmt.Sprintf("stop area member %s %d should have role '%s'", member.Type, member.Ref, expected)
+			return &ValidationError{URL: area.GetElementURL(), Message: msg}
+		}
+	}
+	return nil
+}
+
+// validateStopAreaName checks that a stop or platform has the same name as its stop area. Stop positions are often not
+// named, as they take the name of the stop area
+func (v *Validator) validateStopAreaName(ctx context.Context, area osm.Relation, member osm.Member) (*ValidationError, error) {
+	areaName, found := area.Tags["name"]
+	if !found {
+		return nil, nil
+	}
+
+	tags, err := v.getMemberTags(ctx, member)
+	if err != nil {
+		return nil, err
+	}
+	name, found := tags["name"]
+	if !found || name == areaName {
+		return nil, nil
+	}
+
+	msg := fmt.Sprintf("%s name '%s' does not match stop area name '%s'", getStopOrPlatformName(member), name, areaName)
+	return &ValidationError{URL: member.GetElementURL(), Message: msg}, nil
+}
+
+// getMemberTags returns the tags of a node or way member. Members which can't be loaded have no tags, as they are
+// reported by validateRelationNodes
+func (v *Validator) getMemberTags(ctx context.Context, member osm.Member) (map[string]string, error) {
+	switch member.Type {
+	case "node":
+		nodes, loadErrs := v.provider.LoadNodes(ctx, []int64{member.Ref})
+		if err, found := loadErrs[member.Ref]; found {
+			_, err = memberLoadError(member, err)
+			return nil, err
+		}
+		return nodes[member.Ref].Tags, nil
+	case "way":
+		ways, loadErrs := v.provider.LoadWays(ctx, []int64{member.Ref})
+		if err, found := loadErrs[member.Ref]; found {
+			_, err = memberLoadError(member, err)
+			return nil, err
+		}
+		return ways[member.Ref].Tags, nil
+	}
+	return nil, nil
+}
+
+func isStopOrPlatform(member osm.Member) bool {
+	return (member.Type == "node" && member.RoleIsStop()) || (member.RoleIsPlatform() && member.Type != "relation")
+}
+
+func getStopOrPlatformName(member osm.Member) string {
+	if member.RoleIsStop() {
+		return "stop position"
+	}
+	return "platform"
+}
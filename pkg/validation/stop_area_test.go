@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateStopAreas(t *testing.T) {
+	testcases := []struct {
+		name      string
+		members   []osm.Member
+		stopAreas bool
+		expected  []ValidationError
+	}{
+		{
+			name:      "should not check stop areas by default",
+			members:   []osm.Member{setupPlatform("node", 133)},
+			stopAreas: false,
+			expected:  nil,
+		},
+		{
+			name:      "stop and platform in a valid stop area",
+			members:   []osm.Member{setupStop(140), setupPlatform("node", 142)},
+			stopAreas: true,
+			expected:  []ValidationError{},
+		},
+		{
+			name:      "platform not named after its stop area",
+			members:   []osm.Member{setupStop(141), setupPlatform("node", 143)},
+			stopAreas: true,
+			expected:  []ValidationError{{URL: "https://www.openstreetmap.org/node/143", Message: "platform name 'Stop Bee' does not match stop area name 'Stop B'"}},
+		},
+		{
+			name:      "platform not in a stop area",
+			members:   []osm.Member{setupPlatform("node", 133)},
+			stopAreas: true,
+			expected:  []ValidationError{{URL: "https://www.openstreetmap.org/node/133", Message: "platform is not in a stop area"}},
+		},
+		{
+			name:      "invalid stop areas",
+			members:   []osm.Member{setupStop(147), setupPlatform("node", 148)},
+			stopAreas: true,
+			expected: []ValidationError{
+				{URL: "https://www.openstreetmap.org/relation/502", Message: "stop area member node 147 should have role 'stop'"},
+				{URL: "https://www.openstreetmap.org/node/148", Message: "platform is in more than one stop area (relations 502, 503)"},
+				{URL: "https://www.openstreetmap.org/relation/502", Message: "missing tag 'name'"},
+				{URL: "https://www.openstreetmap.org/relation/502", Message: "missing tag 'type'"},
+			},
+		},
+	}
+
+	store, err := loadTestStore()
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.StopAreas = tc.stopAreas
+			validator := NewValidator(config, store)
+			relation := osm.Relation{Members: tc.members}
+
+			ctx := context.Background()
+			stopAreas, err := validator.loadStopAreas(ctx, relation)
+			require.NoError(t, err)
+			validationErrors, err := validator.validateStopAreas(ctx, relation, stopAreas)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, validationErrors)
+		})
+	}
+}
+
+func TestValidator_AddStopAreas(t *testing.T) {
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	relation := osm.Relation{Members: []osm.Member{
+		{Type: "node", Ref: 140, Role: osm.RoleStop},
+		{Type: "node", Ref: 148, Role: osm.RolePlatform},
+	}}
+
+	full := osm.FullRelation{Relation: relation}
+	require.NoError(t, NewValidator(DefaultConfig(), store).AddStopAreas(context.Background(), &full))
+	assert.Nil(t, full.Relations)
+
+	config := DefaultConfig()
+	config.StopAreas = true
+	require.NoError(t, NewValidator(config, store).AddStopAreas(context.Background(), &full))
+	assert.ElementsMatch(t, []int64{500, 502, 503}, slices.Collect(maps.Keys(full.Relations)))
+}
+
+// parentCountingProvider counts the requests for parent relations
+type parentCountingProvider struct {
+	osm.Provider
+	requests int
+}
+
+func (p *parentCountingProvider) GetParentRelations(ctx context.Context, elemType string, id int64) ([]osm.Relation, error) {
+	p.requests++
+	return p.Provider.GetParentRelations(ctx, elemType, id)
+}
+
+func TestValidator_getStopAreas_cached(t *testing.T) {
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	provider := &parentCountingProvider{Provider: store}
+	relation := osm.Relation{Members: []osm.Member{
+		{Type: "node", Ref: 140, Role: osm.RoleStop},
+		{Type: "node", Ref: 148, Role: osm.RolePlatform},
+	}}
+
+	config := DefaultConfig()
+	config.StopAreas = true
+	validator := NewValidator(config, provider)
+	full := osm.FullRelation{Relation: relation}
+	require.NoError(t, validator.AddStopAreas(context.Background(), &full))
+	assert.Equal(t, 2, provider.requests)
+
+	//Validating the relation reuses the stop areas loaded for the fingerprint
+	_, err = validator.loadStopAreas(context.Background(), relation)
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.requests)
+}
@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"slices"
+
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 )
 
@@ -64,10 +66,32 @@ func getNodesInOrder(direction wayTraversal, we osm.Way) []int64 {
 	return reversed
 }
 
+// getTraversedNodes returns the nodes of a way in the order they are traversed, leaving out any part of the way which
+// the route doesn't use
+func getTraversedNodes(d wayDirection) []int64 {
+	if d.wayElem.IsCircular() {
+		if d.entry == 0 || d.exit == 0 {
+			//The route starts or ends on the way, so it isn't known which part is used
+			return getNodesInOrder(d.direction, d.wayElem)
+		}
+		return getArc(d.wayElem, d.entry, d.exit, d.direction)
+	}
+	nodes := getNodesInOrder(d.direction, d.wayElem)
+	start := 0
+	if i := slices.Index(nodes, d.entry); d.entry != 0 && i >= 0 {
+		start = i
+	}
+	end := len(nodes)
+	if i := slices.Index(nodes[start:], d.exit); d.exit != 0 && i >= 0 {
+		end = start + i + 1
+	}
+	return nodes[start:end]
+}
+
 func getAllNodesInOrder(wayDirects []wayDirection) []int64 {
 	allNodes := []int64{}
 	for _, direct := range wayDirects {
-		thisNodes := getNodesInOrder(direct.direction, direct.wayElem)
+		thisNodes := getTraversedNodes(direct)
 		allNodes = append(allNodes, thisNodes...)
 	}
 	return allNodes
@@ -70,6 +70,32 @@ func Test_validateStopOrder(t *testing.T) {
 				assert.Empty(t, validationErrors)
 			},
 		},
+		{
+			name:     "stop on part of way which is not traversed",
+			relation: makeRelation(102, 105),
+			wayDirects: []wayDirection{
+				{direction: traverseForward, wayElem: osm.Way{Nodes: []int64{101, 102, 103, 104}}, exit: 103},
+				makeWayWithDirection(traverseForward, 103, 105),
+			},
+			checkFn: func(t *testing.T, validationErrors []ValidationError) {
+				assert.Empty(t, validationErrors)
+			},
+		},
+		{
+			name:     "stop on part of way which is not traversed, when the route turns back",
+			relation: makeRelation(102, 104),
+			wayDirects: []wayDirection{
+				{direction: traverseForward, wayElem: osm.Way{Nodes: []int64{101, 102, 103, 104}}, exit: 103},
+				makeWayWithDirection(traverseForward, 103, 105),
+			},
+			checkFn: func(t *testing.T, validationErrors []ValidationError) {
+				exp := ValidationError{
+					URL:     "https://www.openstreetmap.org/node/104",
+					Message: "stop is not on route",
+				}
+				assert.Equal(t, []ValidationError{exp}, validationErrors)
+			},
+		},
 		{
 			name:     "multiple stops in correct order on reversed way",
 			relation: makeRelation(104, 102),
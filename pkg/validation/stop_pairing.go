@@ -0,0 +1,154 @@
+package validation
+
+import (
+	"context"
+	"math"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+// StopPairing is how strictly stop positions are checked against the platforms they serve
+type StopPairing string
+
+const (
+	// StopPairingOff doesn't check pairs
+	StopPairingOff StopPairing = ""
+	// StopPairingOrder checks that each platform is listed straight after its stop position
+	StopPairingOrder StopPairing = "order"
+	// StopPairingStrict also reports stop positions without a platform, and platforms without a stop position
+	StopPairingStrict StopPairing = "strict"
+)
+
+// stopPair is a stop position and platform, as indexes of the relation members
+type stopPair struct {
+	stop     int
+	platform int
+}
+
+// validateStopPairing pairs each stop position with the platform it serves. A stop and platform are paired if they
+// are in the same stop_area relation, otherwise with the nearest platform
+func (v *Validator) validateStopPairing(ctx context.Context, re osm.Relation, stopAreas stopAreaMembership) ([]ValidationError, error) {
+	if v.config.StopPairing == StopPairingOff {
+		return nil, nil
+	}
+
+	stops := []int{}
+	platforms := []int{}
+	for i, member := range re.Members {
+		if !isStopOrPlatform(member) {
+			continue
+		}
+		if member.RoleIsStop() {
+			stops = append(stops, i)
+		} else {
+			platforms = append(platforms, i)
+		}
+	}
+
+	pairs, err := v.pairStops(ctx, re.Members, stops, platforms, stopAreas)
+	if err != nil {
+		return nil, err
+	}
+
+	validationErrors := []ValidationError{}
+	pairedStops := map[int]bool{}
+	pairedPlatforms := map[int]bool{}
+	for _, pair := range pairs {
+		pairedStops[pair.stop] = true
+		pairedPlatforms[pair.platform] = true
+		if pair.platform != pair.stop+1 {
+			platform := re.Members[pair.platform]
+			ve := ValidationError{URL: platform.GetElementURL(), Message: "platform should be listed straight after its stop position"}
+			validationErrors = append(validationErrors, ve)
+		}
+	}
+
+	if v.config.StopPairing != StopPairingStrict {
+		return validationErrors, nil
+	}
+	for _, i := range stops {
+		if !pairedStops[i] && !v.config.isMemberErrorIgnored(re.Members[i]) {
+			validationErrors = append(validationErrors, ValidationError{URL: re.Members[i].GetElementURL(), Message: "stop position has no platform"})
+		}
+	}
+	for _, i := range platforms {
+		if !pairedPlatforms[i] && !v.config.isMemberErrorIgnored(re.Members[i]) {
+			validationErrors = append(validationErrors, ValidationError{URL: re.Members[i].GetElementURL(), Message: "platform has no stop position"})
+		}
+	}
+	return validationErrors, nil
+}
+
+// pairStops pairs stops and platforms in the same stop_area first, so that pairing by distance doesn't take a platform
+// which belongs with another stop. Pairs are returned in the order of the stops
+func (v *Validator) pairStops(ctx context.Context, members []osm.Member, stops []int, platforms []int, stopAreas stopAreaMembership) ([]stopPair, error) {
+	areaIds := map[int]map[int64]bool{}
+	points := map[int]osm.Node{}
+	for _, i := range append(append([]int{}, stops...), platforms...) {
+		areaIds[i] = map[int64]bool{}
+		for _, area := range stopAreas[i] {
+			areaIds[i][area.ID] = true
+		}
+
+		point, found, err := v.getPlatformPoint(ctx, members[i])
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			points[i] = point
+		}
+	}
+
+	paired := map[int]int{}
+	usedPlatforms := map[int]bool{}
+	sharesStopArea := func(stop int, platform int) bool {
+		for id := range areaIds[stop] {
+			if areaIds[platform][id] {
+				return true
+			}
+		}
+		return false
+	}
+	isNearby := func(stop int, platform int) bool {
+		stopPoint, foundStop := points[stop]
+		platformPoint, foundPlatform := points[platform]
+		return foundStop && foundPlatform && distance(stopPoint, platformPoint) <= v.config.GetMaxPlatformDistance()
+	}
+
+	for _, match := range []func(stop int, platform int) bool{sharesStopArea, isNearby} {
+		for _, stop := range stops {
+			if _, found := paired[stop]; found {
+				continue
+			}
+			best := -1
+			bestDistance := math.Inf(1)
+			for _, platform := range platforms {
+				if usedPlatforms[platform] || !match(stop, platform) {
+					continue
+				}
+				d := math.Inf(1)
+				if stopPoint, found := points[stop]; found {
+					if platformPoint, found := points[platform]; found {
+						d = distance(stopPoint, platformPoint)
+					}
+				}
+				if best < 0 || d < bestDistance {
+					best = platform
+					bestDistance = d
+				}
+			}
+			if best >= 0 {
+				paired[stop] = best
+				usedPlatforms[best] = true
+			}
+		}
+	}
+
+	pairs := []stopPair{}
+	for _, stop := range stops {
+		if platform, found := paired[stop]; found {
+			pairs = append(pairs, stopPair{stop: stop, platform: platform})
+		}
+	}
+	return pairs, nil
+}
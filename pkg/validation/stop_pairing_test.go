@@ -0,0 +1,116 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateStopPairing(t *testing.T) {
+	testcases := []struct {
+		name        string
+		members     []osm.Member
+		stopPairing StopPairing
+		ignoreNodes []int64
+		expected    []ValidationError
+	}{
+		{
+			name:        "should not check pairs by default",
+			members:     []osm.Member{setupStop(140), setupStop(141), setupPlatform("node", 142), setupPlatform("node", 143)},
+			stopPairing: StopPairingOff,
+			expected:    nil,
+		},
+		{
+			name:        "each stop followed by its platform",
+			members:     []osm.Member{setupStop(140), setupPlatform("node", 142), setupStop(141), setupPlatform("node", 143)},
+			stopPairing: StopPairingStrict,
+			expected:    []ValidationError{},
+		},
+		{
+			name:        "platforms not listed after their stops",
+			members:     []osm.Member{setupStop(140), setupStop(141), setupPlatform("node", 142), setupPlatform("node", 143)},
+			stopPairing: StopPairingOrder,
+			expected: []ValidationError{
+				{URL: "https://www.openstreetmap.org/node/142", Message: "platform should be listed straight after its stop position"},
+				{URL: "https://www.openstreetmap.org/node/143", Message: "platform should be listed straight after its stop position"},
+			},
+		},
+		{
+			name:        "platform listed before its stop",
+			members:     []osm.Member{setupPlatform("node", 142), setupStop(140)},
+			stopPairing: StopPairingOrder,
+			expected:    []ValidationError{{URL: "https://www.openstreetmap.org/node/142", Message: "platform should be listed straight after its stop position"}},
+		},
+		{
+			name:        "should pair by stop area before distance",
+			members:     []osm.Member{setupStop(141), setupPlatform("node", 144), setupPlatform("node", 143)},
+			stopPairing: StopPairingStrict,
+			expected: []ValidationError{
+				{URL: "https://www.openstreetmap.org/node/143", Message: "platform should be listed straight after its stop position"},
+				{URL: "https://www.openstreetmap.org/node/144", Message: "platform has no stop position"},
+			},
+		},
+		{
+			name:        "should pair by distance without a stop area",
+			members:     []osm.Member{setupStop(145), setupPlatform("node", 146)},
+			stopPairing: StopPairingStrict,
+			expected:    []ValidationError{},
+		},
+		{
+			name:        "unpaired stop and platform",
+			members:     []osm.Member{setupStop(140), setupPlatform("node", 133)},
+			stopPairing: StopPairingStrict,
+			expected: []ValidationError{
+				{URL: "https://www.openstreetmap.org/node/140", Message: "stop position has no platform"},
+				{URL: "https://www.openstreetmap.org/node/133", Message: "platform has no stop position"},
+			},
+		},
+		{
+			name:        "should ignore unpaired nodes in the ignore list",
+			members:     []osm.Member{setupStop(140), setupPlatform("node", 133)},
+			stopPairing: StopPairingStrict,
+			ignoreNodes: []int64{140, 133},
+			expected:    []ValidationError{},
+		},
+		{
+			name:        "should not apply the node ignore list to ways",
+			members:     []osm.Member{setupStop(140), {Type: "way", Ref: 19, Role: osm.RolePlatform}},
+			stopPairing: StopPairingStrict,
+			ignoreNodes: []int64{19},
+			expected: []ValidationError{
+				{URL: "https://www.openstreetmap.org/node/140", Message: "stop position has no platform"},
+				{URL: "https://www.openstreetmap.org/way/19", Message: "platform has no stop position"},
+			},
+		},
+		{
+			name:        "should only report unpaired stops when strict",
+			members:     []osm.Member{setupStop(140), setupPlatform("node", 133)},
+			stopPairing: StopPairingOrder,
+			expected:    []ValidationError{},
+		},
+	}
+
+	store, err := loadTestStore()
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.StopPairing = tc.stopPairing
+			config.Ignore.Nodes.Any = tc.ignoreNodes
+			validator := NewValidator(config, store)
+
+			relation := osm.Relation{Members: tc.members}
+
+			ctx := context.Background()
+			stopAreas, err := validator.loadStopAreas(ctx, relation)
+			require.NoError(t, err)
+			validationErrors, err := validator.validateStopPairing(ctx, relation, stopAreas)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, validationErrors)
+		})
+	}
+}
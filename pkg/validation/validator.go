@@ -6,28 +6,45 @@ import (
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 )
 
-func DefaultValidator(client *osm.OSMClient) *Validator {
-	return &Validator{config: DefaultConfig(), osmClient: client}
+// Version identifies the checks the validator makes. It must be incremented whenever a check is added or changed, so
+// that results saved by an older version are not reused
+const Version = 9
+
+func DefaultValidator(provider osm.Provider) *Validator {
+	return &Validator{config: DefaultConfig(), provider: provider}
 }
 
-func NewValidator(config Config, client *osm.OSMClient) *Validator {
-	return &Validator{config: config, osmClient: client}
+func NewValidator(config Config, provider osm.Provider) *Validator {
+	return &Validator{config: config, provider: provider}
 }
 
 type Validator struct {
-	config    Config
-	osmClient *osm.OSMClient
+	config   Config
+	provider osm.Provider
+	// stopAreas caches the stop areas of each stop and platform, keyed by element type and ID, e.g. node/123
+	stopAreas map[string][]osm.Relation
 }
 
 func (v *Validator) GetConfig() Config {
 	return v.config
 }
 
+// ValidationError is a problem with a relation or one of its members. The message doesn't change with the details of
+// the problem, such as distances, which are in Detail, so that the same error can be found in earlier versions
 type ValidationError struct {
 	URL     string `json:"url,omitempty"`
 	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
 }
 
 func (v ValidationError) String() string {
+	if v.Detail != "" {
+		return fmt.Sprintf("%s (%s) - %s", v.Message, v.Detail, v.URL)
+	}
 	return fmt.Sprintf("%s - %s", v.Message, v.URL)
 }
+
+// withoutDetail returns the error without its detail, for comparing with errors found by another validation
+func (v ValidationError) withoutDetail() ValidationError {
+	return ValidationError{URL: v.URL, Message: v.Message}
+}
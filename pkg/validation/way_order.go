@@ -3,15 +3,24 @@ package validation
 import (
 	"context"
 	"fmt"
+	"math"
 	"slices"
+	"strings"
 
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 )
 
-func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]ValidationError, []wayDirection, error) {
+// nearMissDistance is the distance in metres below which disconnected way ends are probably meant to share a node
+const nearMissDistance = 5.0
+
+// validateWayOrder checks that the member ways form a continuous route. Ways which need splitting where the route turns
+// part-way along them are returned separately, as the route is otherwise valid and the stop order can still be checked.
+// The way directions are only returned if the ways form a route
+func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]ValidationError, []ValidationError, []wayDirection, error) {
 	wayIds := []int64{}
 	ways := []osm.Member{}
 	validationErrors := []ValidationError{}
+	splitErrors := []ValidationError{}
 
 	for _, member := range re.Members {
 		if member.Type == "way" && member.Role == "" {
@@ -20,20 +29,28 @@ func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]Va
 		}
 	}
 
-	waysMap := v.osmClient.LoadWays(ctx, wayIds)
+	waysMap, loadErrs := v.provider.LoadWays(ctx, wayIds)
 
-	//Check for any nil ways
-	for k, way := range waysMap {
-		if way == nil {
-			return nil, nil, fmt.Errorf("failed to load way %d", k)
+	for _, member := range ways {
+		if err, found := loadErrs[member.Ref]; found {
+			ve, err := memberLoadError(member, err)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			validationErrors = append(validationErrors, ve)
 		}
 	}
+	if len(validationErrors) > 0 {
+		//Can't check the order of the ways if any are missing
+		return validationErrors, splitErrors, nil, nil
+	}
 
 	allowedNodes := map[int64]bool{}
 	var wayDirects []wayDirection
 	hasGap := false
+	var endNodes map[int64]*osm.Node
 
-	for _, relationMemberWay := range ways {
+	for i, relationMemberWay := range ways {
 		wayElem := *waysMap[relationMemberWay.Ref]
 
 		if len(allowedNodes) == 0 {
@@ -50,10 +67,12 @@ func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]Va
 		wayDir := traverseAny
 		nextAllowedNodes := map[int64]bool{}
 		matches := 0
+		var joinNode int64
 		for an := range allowedNodes {
 			if wayElem.IsCircular() {
 				if slices.Contains(wayElem.Nodes, an) {
 					nextAllowedNodes = mapFromNodes(wayElem.Nodes)
+					joinNode = an
 					matches++
 				}
 			} else if an == wayElem.GetFirstNode() {
@@ -63,6 +82,7 @@ func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]Va
 					nextAllowedNodes[wayElem.GetLastNode()] = true
 					wayDir = traverseForward
 				}
+				joinNode = an
 				matches++
 			} else if an == wayElem.GetLastNode() {
 				if wayElem.IsCircular() {
@@ -72,20 +92,54 @@ func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]Va
 					nextAllowedNodes[wayElem.GetFirstNode()] = true
 					wayDir = traverseReverse
 				}
+				joinNode = an
 				matches++
 			}
 		}
 
 		switch matches {
 		case 0:
-			ve := ValidationError{URL: wayElem.GetElementURL(), Message: "ways are incorrectly ordered"}
+			previous := &wayDirects[len(wayDirects)-1]
+			if splitNode, found := findSplitNode(*previous, wayElem); found {
+				//The route turns between the ways part-way along one or both of them
+				if isInnerNode(previous.wayElem, splitNode) {
+					previous.exit = splitNode
+					splitErrors = append(splitErrors, getSplitError(previous.wayElem, splitNode))
+				} else if previous.wayElem.IsCircular() {
+					previous.exit = splitNode
+				}
+				var entry int64
+				if isInnerNode(wayElem, splitNode) {
+					entry = splitNode
+					splitErrors = append(splitErrors, getSplitError(wayElem, splitNode))
+				}
+				wayDir, allowedNodes = getTraversalFrom(wayElem, splitNode)
+				wayDirects = append(wayDirects, wayDirection{wayElem: wayElem, direction: wayDir, entry: entry})
+				continue
+			}
+
+			if endNodes == nil {
+				endNodes = v.loadEndNodes(ctx, waysMap)
+			}
+			gap := describeGap(wayDirects[len(wayDirects)-1].wayElem, wayElem, allowedNodes, endNodes, waysMap, ways[i+1:])
+			ve := ValidationError{URL: wayElem.GetElementURL(), Message: "ways are incorrectly ordered", Detail: gap}
 			validationErrors = append(validationErrors, ve)
 			allowedNodes = mapFromNodes(wayElem.Nodes)
 			hasGap = true
 		case 1:
 			allowedNodes = nextAllowedNodes
+			//Record where the route joins and leaves circular ways, to work out which way round them it goes
+			if previous := &wayDirects[len(wayDirects)-1]; previous.wayElem.IsCircular() {
+				previous.exit = joinNode
+			}
+			if wayElem.IsCircular() {
+				wayDirects = append(wayDirects, wayDirection{wayElem: wayElem, direction: wayDir, entry: joinNode})
+				continue
+			}
 		default:
-			wayDir = traverseTBC
+			if !wayElem.IsCircular() {
+				wayDir = traverseTBC
+			}
 			allowedNodes = nextAllowedNodes
 		}
 
@@ -93,11 +147,31 @@ func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]Va
 	}
 
 	if hasGap {
+		//Say which ways are out of place, if they can be reordered into a continuous route. If the search is cut off, the
+		//ways found may not be the fewest to move, so they aren't reported
+		solveCtx, cancel := context.WithTimeout(ctx, maxSolveTime)
+		order := v.solveWayOrder(solveCtx, ways, waysMap)
+		cancel()
+		if order != nil && order.Minimal {
+			validationErrors = append(validationErrors, getOutOfPlaceErrors(order)...)
+		}
 		//Don't bother checking one-way traversal
-		return validationErrors, nil, nil
+		return validationErrors, splitErrors, nil, nil
 	}
 
-	wayDirects = fillInMissingWayDirects(wayDirects)
+	stops := []int64{}
+	for _, member := range re.Members {
+		if member.Type == "node" && member.RoleIsStop() {
+			stops = append(stops, member.Ref)
+		}
+	}
+	wayDirects = fillInMissingWayDirects(wayDirects, stops)
+	stopNodes := getStopNodes(re)
+	for i, d := range wayDirects {
+		if d.wayElem.IsCircular() && d.entry != 0 && d.exit != 0 {
+			wayDirects[i].direction = v.getCircularDirection(d, stopNodes)
+		}
+	}
 
 	for _, d := range wayDirects {
 		wayElem := d.wayElem
@@ -107,19 +181,90 @@ func (v *Validator) validateWayOrder(ctx context.Context, re osm.Relation) ([]Va
 		}
 	}
 
-	return validationErrors, wayDirects, nil
+	return validationErrors, splitErrors, wayDirects, nil
+}
+
+// loadEndNodes loads the nodes which member ways can join at, i.e. the ends of linear ways and every node of circular
+// ways. Nodes which can't be loaded are left out, as they are only used to describe gaps
+func (v *Validator) loadEndNodes(ctx context.Context, waysMap map[int64]*osm.Way) map[int64]*osm.Node {
+	nodeIds := []int64{}
+	for _, way := range waysMap {
+		nodeIds = append(nodeIds, getJoiningNodes(*way)...)
+	}
+	slices.Sort(nodeIds)
+	nodes, _ := v.provider.LoadNodes(ctx, slices.Compact(nodeIds))
+	return nodes
+}
+
+// describeGap returns the detail for a gap between two consecutive member ways: the distance between their closest
+// ends, and which of the remaining member ways would join on from the previous way
+func describeGap(previous osm.Way, wayElem osm.Way, allowedNodes map[int64]bool, endNodes map[int64]*osm.Node, waysMap map[int64]*osm.Way, remaining []osm.Member) string {
+	details := []string{}
+
+	gap := math.Inf(1)
+	for an := range allowedNodes {
+		from, found := endNodes[an]
+		if !found {
+			continue
+		}
+		for _, nid := range getJoiningNodes(wayElem) {
+			if to, found := endNodes[nid]; found {
+				gap = min(gap, distance(*from, *to))
+			}
+		}
+	}
+	if !math.IsInf(gap, 1) {
+		details = append(details, fmt.Sprintf("gap of %s from way %d", formatDistance(gap), previous.ID))
+		if gap <= nearMissDistance {
+			details = append(details, "probably a missing shared node")
+		}
+	}
+
+	for _, member := range remaining {
+		candidate := waysMap[member.Ref]
+		if candidate.ID == wayElem.ID || candidate.ID == previous.ID {
+			continue
+		}
+		if slices.ContainsFunc(getJoiningNodes(*candidate), func(nid int64) bool { return allowedNodes[nid] }) {
+			details = append(details, fmt.Sprintf("way %d would connect", candidate.ID))
+			break
+		}
+	}
+
+	return strings.Join(details, ", ")
+}
+
+// getJoiningNodes returns the nodes which another way can join a way at
+func getJoiningNodes(way osm.Way) []int64 {
+	if len(way.Nodes) == 0 || way.IsCircular() {
+		return way.Nodes
+	}
+	return []int64{way.GetFirstNode(), way.GetLastNode()}
 }
 
-func fillInMissingWayDirects(wayDirects []wayDirection) []wayDirection {
+// fillInMissingWayDirects works out the direction of ways which couldn't be told from the way before. Most are worked out
+// from the way after, and ways which the route leaves part-way along, or which end the route, from getPartialDirection
+func fillInMissingWayDirects(wayDirects []wayDirection, stops []int64) []wayDirection {
 
 	var previousWD wayDirection
 	for i := (len(wayDirects) - 1); i >= 0; i-- {
 		if wayDirects[i].direction == "tbc" {
 			pw := previousWD.wayElem
-			if pw.IsCircular() {
+			if wayDirects[i].exit != 0 || len(pw.Nodes) == 0 {
+				var before *osm.Way
+				if i > 0 {
+					before = &wayDirects[i-1].wayElem
+				}
+				wayDirects[i].direction = getPartialDirection(wayDirects[i], before, stops)
+				if wayDirects[i].direction == traverseAny {
+					//It isn't known which part of the way the route uses
+					wayDirects[i].entry = 0
+					wayDirects[i].exit = 0
+				}
+			} else if pw.IsCircular() {
 				wayDirects[i].direction = getDirectionJoinCircular(pw, wayDirects[i].wayElem)
 			} else {
-				wayDirects[i].direction = getDirectionJoinLinear(pw, previousWD.direction, wayDirects[i].wayElem)
+				wayDirects[i].direction = getDirectionJoinLinear(previousWD, wayDirects[i].wayElem)
 			}
 		}
 		previousWD = wayDirects[i]
@@ -150,11 +295,13 @@ func getDirectionJoinCircular(circularWay osm.Way, joiningWay osm.Way) wayTraver
 	return traverseError
 }
 
-func getDirectionJoinLinear(secondWay osm.Way, direction wayTraversal, joiningWay osm.Way) wayTraversal {
+func getDirectionJoinLinear(second wayDirection, joiningWay osm.Way) wayTraversal {
 	lastNode := joiningWay.GetLastNode()
-	compareNode := secondWay.GetFirstNode()
-	if direction == traverseReverse {
-		compareNode = secondWay.GetLastNode()
+	compareNode := second.wayElem.GetFirstNode()
+	if second.entry != 0 {
+		compareNode = second.entry
+	} else if second.direction == traverseReverse {
+		compareNode = second.wayElem.GetLastNode()
 	}
 
 	if compareNode == lastNode {
@@ -163,6 +310,156 @@ func getDirectionJoinLinear(secondWay osm.Way, direction wayTraversal, joiningWa
 	return traverseReverse
 }
 
+// getCircularDirection works out which way round a circular way the route goes between where it joins and leaves the
+// way. Stops on the way show the direction, otherwise it is the direction allowed by any oneway tag, or the shorter way
+// round
+func (v *Validator) getCircularDirection(d wayDirection, stopNodes map[int64]bool) wayTraversal {
+	forward := getArc(d.wayElem, d.entry, d.exit, traverseForward)
+	reverse := getArc(d.wayElem, d.entry, d.exit, traverseReverse)
+	forwardStops := countStops(forward, stopNodes)
+	reverseStops := countStops(reverse, stopNodes)
+	if forwardStops != reverseStops {
+		if forwardStops > reverseStops {
+			return traverseForward
+		}
+		return traverseReverse
+	}
+
+	canForward := v.checkOneway(d.wayElem, traverseForward)
+	canReverse := v.checkOneway(d.wayElem, traverseReverse)
+	if canForward != canReverse {
+		if canForward {
+			return traverseForward
+		}
+		return traverseReverse
+	}
+	if len(reverse) < len(forward) {
+		return traverseReverse
+	}
+	return traverseForward
+}
+
+// getArc returns the nodes of a circular way from the entry node to the exit node, going forward or in reverse. If the
+// route leaves at the node it joined at, it goes all the way round
+func getArc(way osm.Way, entry int64, exit int64, direction wayTraversal) []int64 {
+	ring := way.Nodes[:len(way.Nodes)-1]
+	step := 1
+	if direction == traverseReverse {
+		step = len(ring) - 1
+	}
+
+	i := slices.Index(ring, entry)
+	if i < 0 || !slices.Contains(ring, exit) {
+		return getNodesInOrder(direction, way)
+	}
+	arc := []int64{ring[i]}
+	for {
+		i = (i + step) % len(ring)
+		arc = append(arc, ring[i])
+		if ring[i] == exit {
+			return arc
+		}
+	}
+}
+
+// countStops counts the stops part-way along an arc, i.e. not where the route joins or leaves the way
+func countStops(arc []int64, stopNodes map[int64]bool) int {
+	count := 0
+	for _, nid := range arc[1 : len(arc)-1] {
+		if stopNodes[nid] {
+			count++
+		}
+	}
+	return count
+}
+
+func getStopNodes(re osm.Relation) map[int64]bool {
+	stopNodes := map[int64]bool{}
+	for _, member := range re.Members {
+		if member.Type == "node" && member.RoleIsStop() {
+			stopNodes[member.Ref] = true
+		}
+	}
+	return stopNodes
+}
+
+// getPartialDirection returns the direction of a way from where the route joins it to where it leaves. If the route
+// doesn't join the way part-way along, it joins at the end shared with the way before, or for the first way, the end
+// before the first stop on the way. If the route doesn't leave the way, i.e. the way ends the route, it leaves after the
+// last stop. It returns traverseAny if the direction can't be told
+func getPartialDirection(d wayDirection, before *osm.Way, stops []int64) wayTraversal {
+	nodes := d.wayElem.Nodes
+	from := slices.Index(nodes, d.entry)
+	if d.entry == 0 && before != nil {
+		switch first, last := slices.Contains(before.Nodes, nodes[0]), slices.Contains(before.Nodes, nodes[len(nodes)-1]); {
+		case first && !last:
+			from = 0
+		case last && !first:
+			from = len(nodes) - 1
+		}
+	} else if d.entry == 0 {
+		from = slices.IndexFunc(nodes, func(nid int64) bool { return len(stops) > 0 && nid == stops[0] })
+	}
+
+	to := slices.Index(nodes, d.exit)
+	if d.exit == 0 {
+		to = slices.IndexFunc(nodes, func(nid int64) bool { return len(stops) > 0 && nid == stops[len(stops)-1] })
+	}
+
+	switch {
+	case from < 0 || to < 0 || from == to:
+		return traverseAny
+	case from < to:
+		return traverseForward
+	default:
+		return traverseReverse
+	}
+}
+
+// findSplitNode returns the node where the route can turn from the previous way onto a way when they don't join at
+// their ends, i.e. a node shared part-way along one or both of them
+func findSplitNode(previous wayDirection, way osm.Way) (int64, bool) {
+	candidates := previous.wayElem.Nodes
+	if previous.direction == traverseForward || previous.direction == traverseReverse {
+		//The route can only turn off after the node where it joined the previous way
+		candidates = getTraversedNodes(previous)[1:]
+	}
+	for _, nid := range candidates {
+		if nid == previous.entry || !slices.Contains(way.Nodes, nid) {
+			continue
+		}
+		if isInnerNode(previous.wayElem, nid) || isInnerNode(way, nid) {
+			return nid, true
+		}
+	}
+	return 0, false
+}
+
+// getTraversalFrom returns the direction of a way joined at a node, and the nodes the route can leave it at
+func getTraversalFrom(way osm.Way, node int64) (wayTraversal, map[int64]bool) {
+	switch {
+	case way.IsCircular():
+		return traverseAny, mapFromNodes(way.Nodes)
+	case node == way.GetFirstNode():
+		return traverseForward, map[int64]bool{way.GetLastNode(): true}
+	case node == way.GetLastNode():
+		return traverseReverse, map[int64]bool{way.GetFirstNode(): true}
+	default:
+		return traverseTBC, map[int64]bool{way.GetFirstNode(): true, way.GetLastNode(): true}
+	}
+}
+
+func isInnerNode(way osm.Way, node int64) bool {
+	if len(way.Nodes) < 3 || way.IsCircular() {
+		return false
+	}
+	return slices.Contains(way.Nodes[1:len(way.Nodes)-1], node)
+}
+
+func getSplitError(way osm.Way, node int64) ValidationError {
+	return ValidationError{URL: way.GetElementURL(), Message: fmt.Sprintf("way must be split at node %d", node)}
+}
+
 func (v *Validator) checkOneway(way osm.Way, direction wayTraversal) bool {
 	onewayTag := getOnewayTag(way)
 	if onewayTag == "" {
@@ -208,6 +505,9 @@ func getOnewayTag(way osm.Way) string {
 type wayDirection struct {
 	wayElem   osm.Way
 	direction wayTraversal
+	//entry and exit are the nodes where the route joins and leaves the way, if it is only traversed part-way along
+	entry int64
+	exit  int64
 }
 
 type wayTraversal string
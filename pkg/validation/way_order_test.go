@@ -3,14 +3,12 @@ package validation
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"net/http/httptest"
-	"os"
-	"strings"
+	"path/filepath"
 	"testing"
 
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_validateWayOrder(t *testing.T) {
@@ -50,10 +48,23 @@ func Test_validateWayOrder(t *testing.T) {
 				exp := ValidationError{
 					URL:     "https://www.openstreetmap.org/way/3",
 					Message: "ways are incorrectly ordered",
+					Detail:  "gap of 111 m from way 1, way 2 would connect",
 				}
 				assertContainsValidationError(t, validationErrors, exp)
 			},
 		},
+		{
+			name:    "route with missing way",
+			members: setupWays(1, 2, 99),
+			checkFn: func(t *testing.T, validationErrors []ValidationError, err error) {
+				assert.Nil(t, err)
+				exp := ValidationError{
+					URL:     "https://www.openstreetmap.org/way/99",
+					Message: "member way 99 does not exist",
+				}
+				assert.Equal(t, []ValidationError{exp}, validationErrors)
+			},
+		},
 		{
 			name:    "route with circular way in middle",
 			members: setupWays(3, 4, 5),
@@ -72,10 +83,58 @@ func Test_validateWayOrder(t *testing.T) {
 				exp := ValidationError{
 					URL:     "https://www.openstreetmap.org/way/1",
 					Message: "ways are incorrectly ordered",
+					Detail:  "gap of 445 m from way 4",
 				}
 				assertContainsValidationError(t, validationErrors, exp)
 			},
 		},
+		{
+			name:    "route turning off a way part-way along",
+			members: setupWays(1, 2, 3, 12),
+			checkFn: func(t *testing.T, validationErrors []ValidationError, err error) {
+				assert.Nil(t, err)
+				exp := ValidationError{
+					URL:     "https://www.openstreetmap.org/way/3",
+					Message: "way must be split at node 103",
+				}
+				assert.Equal(t, []ValidationError{exp}, validationErrors)
+			},
+		},
+		{
+			name:    "route joining a way part-way along",
+			members: setupWays(12, 3, 2, 1),
+			checkFn: func(t *testing.T, validationErrors []ValidationError, err error) {
+				assert.Nil(t, err)
+				exp := ValidationError{
+					URL:     "https://www.openstreetmap.org/way/3",
+					Message: "way must be split at node 103",
+				}
+				assert.Equal(t, []ValidationError{exp}, validationErrors)
+			},
+		},
+		{
+			name:    "route going round a roundabout in the correct direction",
+			members: setupWays(14, 13, 15),
+			checkFn: expectedValid,
+		},
+		{
+			name:    "route going round a roundabout in the wrong direction",
+			members: append([]osm.Member{{Type: "node", Ref: 121, Role: osm.RoleStop}}, setupWays(14, 13, 15)...),
+			checkFn: expectedOneWayError(13),
+		},
+		{
+			name:    "route with ways which nearly meet",
+			members: setupWays(1, 11),
+			checkFn: func(t *testing.T, validationErrors []ValidationError, err error) {
+				assert.Nil(t, err)
+				exp := ValidationError{
+					URL:     "https://www.openstreetmap.org/way/11",
+					Message: "ways are incorrectly ordered",
+					Detail:  "gap of 2 m from way 1, probably a missing shared node",
+				}
+				assert.Equal(t, []ValidationError{exp}, validationErrors)
+			},
+		},
 		{
 			name:    "valid route entering and leaving circular way at the same node",
 			members: setupWays(3, 4, 3),
@@ -140,13 +199,10 @@ func Test_validateWayOrder(t *testing.T) {
 		},
 	}
 
-	svr, err := setupTestServer()
+	store, err := loadTestStore()
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer svr.Close()
-
-	osmClient := osm.NewClient("unit-test/0.0").WithBaseUrl(svr.URL)
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -154,13 +210,26 @@ func Test_validateWayOrder(t *testing.T) {
 			if tc.setConfig != nil {
 				tc.setConfig(&c)
 			}
-			validator := NewValidator(c, osmClient)
-			validationErrors, _, err := validator.validateWayOrder(context.Background(), osm.Relation{Members: tc.members})
-			tc.checkFn(t, validationErrors, err)
+			validator := NewValidator(c, store)
+			validationErrors, splitErrors, _, err := validator.validateWayOrder(context.Background(), osm.Relation{Members: tc.members})
+			tc.checkFn(t, append(splitErrors, validationErrors...), err)
 		})
 	}
 }
 
+func Test_validateWayOrder_splitErrors(t *testing.T) {
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
+
+	//The route is valid apart from the way which needs splitting, so the way directions are still returned
+	validationErrors, splitErrors, wayDirects, err := validator.validateWayOrder(context.Background(), osm.Relation{Members: setupWays(1, 2, 3, 12)})
+	require.NoError(t, err)
+	assert.Empty(t, validationErrors)
+	assert.Equal(t, []ValidationError{{URL: "https://www.openstreetmap.org/way/3", Message: "way must be split at node 103"}}, splitErrors)
+	assert.Len(t, wayDirects, 4)
+}
+
 func setupWays(ids ...int64) []osm.Member {
 	members := []osm.Member{}
 	for _, id := range ids {
@@ -169,48 +238,102 @@ func setupWays(ids ...int64) []osm.Member {
 	return members
 }
 
-func setupTestServer() (*httptest.Server, error) {
-	files, err := loadWayFiles()
+func setupStop(id int64) osm.Member {
+	return osm.Member{Type: "node", Ref: id, Role: osm.RoleStop}
+}
+
+func setupPlatform(elemType string, id int64) osm.Member {
+	return osm.Member{Type: elemType, Ref: id, Role: osm.RolePlatform}
+}
+
+func loadTestStore() (*osm.Store, error) {
+	paths, err := filepath.Glob("testdata/way_*.json")
 	if err != nil {
 		return nil, err
 	}
-	handlerFn := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		name := request.RequestURI
-		name = strings.Replace(name, "/way/", "", 1)
-		name = strings.Replace(name, ".json", "", 1)
-		bytes, found := files[name]
-		if !found {
-			writer.WriteHeader(http.StatusNotFound)
-			return
-		}
-		_, err := writer.Write(bytes)
-		if err != nil {
-			panic(err)
-		}
-	})
-	return httptest.NewServer(handlerFn), nil
+	return osm.LoadFiles(append(paths, "testdata/nodes.json", "testdata/stop_areas.json", "testdata/route_masters.json")...)
 }
 
-func loadWayFiles() (map[string][]byte, error) {
-	dir, err := os.ReadDir("testdata")
-	if err != nil {
-		return nil, err
+func Test_validateWayOrder_partialTraversal(t *testing.T) {
+	testcases := []struct {
+		name     string
+		members  []osm.Member
+		expNodes []int64
+	}{
+		{
+			name:     "should start at the end of a way joined part-way along",
+			members:  setupWays(12, 3, 2, 1),
+			expNodes: []int64{117, 103, 103, 102, 102, 101, 101, 100},
+		},
+		{
+			name:     "should start the first way before the first stop",
+			members:  append([]osm.Member{setupStop(105)}, setupWays(3, 12)...),
+			expNodes: []int64{104, 105, 103, 103, 117},
+		},
+		{
+			name:     "should use the whole first way if no stop shows the direction",
+			members:  setupWays(3, 12),
+			expNodes: []int64{102, 103, 105, 104, 103, 117},
+		},
+		{
+			name:     "should end the last way after the last stop",
+			members:  append([]osm.Member{setupStop(117), setupStop(105)}, setupWays(12, 3)...),
+			expNodes: []int64{117, 103, 103, 105, 104},
+		},
+		{
+			name:     "should use the whole last way if no stop shows the direction",
+			members:  setupWays(12, 3),
+			expNodes: []int64{117, 103, 102, 103, 105, 104},
+		},
 	}
 
-	fileMap := map[string][]byte{}
+	store, err := loadTestStore()
+	assert.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
 
-	for _, entry := range dir {
-		name := entry.Name()
-		if strings.HasPrefix(name, "way_") {
-			name = strings.Replace(name, "way_", "", 1)
-			name = strings.Replace(name, ".json", "", 1)
-			bytes, err := os.ReadFile("testdata/" + entry.Name())
-			if err != nil {
-				return nil, err
-			}
-			fileMap[name] = bytes
-		}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, wayDirects, err := validator.validateWayOrder(context.Background(), osm.Relation{Members: tc.members})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expNodes, getAllNodesInOrder(wayDirects))
+		})
+	}
+}
+
+func Test_validateWayOrder_circularArcs(t *testing.T) {
+	testcases := []struct {
+		name     string
+		members  []osm.Member
+		expNodes []int64
+	}{
+		{
+			name:     "should go forward round a roundabout",
+			members:  setupWays(14, 13, 15),
+			expNodes: []int64{122, 118, 118, 119, 120, 120, 123},
+		},
+		{
+			name:     "should go the shorter way round a closed way which is not oneway",
+			members:  setupWays(17, 16, 18),
+			expNodes: []int64{129, 124, 124, 125, 125, 130},
+		},
+		{
+			name:     "should go past stops on a closed way",
+			members:  append([]osm.Member{{Type: "node", Ref: 127, Role: osm.RoleStop}}, setupWays(17, 16, 18)...),
+			expNodes: []int64{129, 124, 124, 128, 127, 126, 125, 125, 130},
+		},
 	}
 
-	return fileMap, nil
+	store, err := loadTestStore()
+	assert.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			validationErrors, splitErrors, wayDirects, err := validator.validateWayOrder(context.Background(), osm.Relation{Members: tc.members})
+			assert.NoError(t, err)
+			assert.Empty(t, validationErrors)
+			assert.Empty(t, splitErrors)
+			assert.Equal(t, tc.expNodes, getAllNodesInOrder(wayDirects))
+		})
+	}
 }
@@ -0,0 +1,344 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+)
+
+// maxPathSteps limits how many partial paths are tried when solving the way order, as the search is exponential for
+// routes with many branching ways
+const maxPathSteps = 200000
+
+// ctxCheckSteps is how often the search checks whether the context is done, starting with the first step
+const ctxCheckSteps = 1000
+
+// maxSolveTime limits the time spent finding the ways which are out of place when validating a route, so that a route
+// with many branching ways doesn't hold up its validation
+const maxSolveTime = 2 * time.Second
+
+// WayOrder is a continuous path through the member ways of a route relation
+type WayOrder struct {
+	// Ways are the way members in path order
+	Ways []osm.Member
+	// OutOfPlace are the way members which need to be moved to put the ways in path order. They are the fewest which
+	// need to be moved if Minimal is true
+	OutOfPlace []osm.Member
+	// Minimal is false if the search was cut off before every path had been tried
+	Minimal    bool
+	moved      []bool
+	wayDirects []wayDirection
+}
+
+// SolveWayOrder finds the order of the member ways which forms a continuous path, respecting oneway tags, and needs
+// the fewest members to be moved. Ways can join part-way along each other, where they would need to be split, but paths
+// with fewer splits are preferred. It returns nil if the ways can't be joined into a single path. If there are too many
+// paths to try them all, the best path found is returned, which may not be minimal
+func (v *Validator) SolveWayOrder(ctx context.Context, re osm.Relation) (*WayOrder, error) {
+	wayIds := []int64{}
+	ways := []osm.Member{}
+	for _, member := range re.Members {
+		if member.Type == "way" && member.Role == "" {
+			wayIds = append(wayIds, member.Ref)
+			ways = append(ways, member)
+		}
+	}
+
+	waysMap, loadErrs := v.provider.LoadWays(ctx, wayIds)
+	for _, member := range ways {
+		if err, found := loadErrs[member.Ref]; found {
+			if _, err := memberLoadError(member, err); err != nil {
+				return nil, err
+			}
+			return nil, nil
+		}
+	}
+	order := v.solveWayOrder(ctx, ways, waysMap)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return order, nil
+}
+
+// Members returns the members of the relation with the ways in path order. Other members keep their positions
+func (o *WayOrder) Members(re osm.Relation) []osm.Member {
+	members := []osm.Member{}
+	next := 0
+	for _, member := range re.Members {
+		if member.Type == "way" && member.Role == "" && next < len(o.Ways) {
+			member = o.Ways[next]
+			next++
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+// solveWayOrder searches for the best path through the ways until every path has been tried, the step limit is reached
+// or the context is done
+func (v *Validator) solveWayOrder(ctx context.Context, ways []osm.Member, waysMap map[int64]*osm.Way) *WayOrder {
+	elems := []osm.Way{}
+	for _, member := range ways {
+		way := waysMap[member.Ref]
+		if way == nil || len(way.Nodes) == 0 {
+			return nil
+		}
+		elems = append(elems, *way)
+	}
+
+	solver := &pathSolver{ctx: ctx, checkOneway: v.checkOneway, ways: elems, nodeWays: map[int64][]int{}, used: make([]bool, len(elems)), bestKept: -1}
+	for i, way := range elems {
+		nodes := way.Nodes
+		if way.IsCircular() {
+			nodes = nodes[:len(nodes)-1]
+		}
+		for _, nid := range nodes {
+			solver.nodeWays[nid] = append(solver.nodeWays[nid], i)
+		}
+	}
+	for _, start := range getStartCandidates(elems) {
+		solver.visit(start, 0)
+		if solver.cutOff {
+			break
+		}
+	}
+	if solver.best == nil {
+		return nil
+	}
+
+	order := &WayOrder{Minimal: !solver.cutOff}
+	kept := longestIncreasing(solver.best)
+	for i, index := range solver.best {
+		order.Ways = append(order.Ways, ways[index])
+		d := wayDirection{wayElem: elems[index], direction: solver.bestDirections[i]}
+		if join := solver.bestJoins[i]; isInnerNode(d.wayElem, join) || d.wayElem.IsCircular() {
+			d.entry = join
+		}
+		if i+1 < len(solver.best) {
+			if join := solver.bestJoins[i+1]; isInnerNode(d.wayElem, join) || d.wayElem.IsCircular() {
+				d.exit = join
+			}
+		}
+		order.wayDirects = append(order.wayDirects, d)
+		order.moved = append(order.moved, !kept[i])
+		if !kept[i] {
+			order.OutOfPlace = append(order.OutOfPlace, ways[index])
+		}
+	}
+	return order
+}
+
+// getOutOfPlaceErrors returns an error for each way which needs to be moved, saying where it should go
+func getOutOfPlaceErrors(order *WayOrder) []ValidationError {
+	validationErrors := []ValidationError{}
+	for i, member := range order.Ways {
+		if !order.moved[i] {
+			continue
+		}
+		ve := ValidationError{URL: member.GetElementURL(), Message: "way is out of place, it should be the first way"}
+		if i > 0 {
+			ve.Message = fmt.Sprintf("way is out of place, it should come after way %d", order.Ways[i-1].Ref)
+		}
+		validationErrors = append(validationErrors, ve)
+	}
+	return validationErrors
+}
+
+// pathSolver does a depth-first search for paths which use every member way once, keeping the path which leaves the
+// most ways in their original order, and then needs the fewest ways to be split
+type pathSolver struct {
+	ctx         context.Context
+	checkOneway func(way osm.Way, direction wayTraversal) bool
+	ways        []osm.Way
+	//nodeWays are the indexes of the ways each node is on
+	nodeWays   map[int64][]int
+	used       []bool
+	path       []int
+	directions []wayTraversal
+	//joins are the nodes where the path joins each way, or 0 for the first way
+	joins  []int64
+	splits int
+	//tails[k] is the smallest way index which ends a run of k+1 ways in the path that keep their original order, so the
+	//number of ways kept in order is len(tails)
+	tails          []int
+	steps          int
+	cutOff         bool
+	best           []int
+	bestDirections []wayTraversal
+	bestJoins      []int64
+	bestKept       int
+	bestSplits     int
+}
+
+// visit tries to continue the path with a way, joining it at a node (0 at the start of the path)
+func (s *pathSolver) visit(index int, join int64) {
+	if s.cutOff {
+		return
+	}
+	s.steps++
+	if s.steps > maxPathSteps || (s.steps%ctxCheckSteps == 1 && s.ctx.Err() != nil) {
+		s.cutOff = true
+		return
+	}
+
+	way := s.ways[index]
+	splits := 0
+	if len(s.path) > 0 {
+		for _, joined := range []osm.Way{s.ways[s.path[len(s.path)-1]], way} {
+			if isInnerNode(joined, join) {
+				splits++
+			}
+		}
+	}
+	for _, next := range getTraversals(way, join) {
+		if !s.checkOneway(way, next.direction) {
+			continue
+		}
+
+		s.used[index] = true
+		s.path = append(s.path, index)
+		s.directions = append(s.directions, next.direction)
+		s.joins = append(s.joins, join)
+		s.splits += splits
+		pos, replaced := s.pushTail(index)
+		s.extend(next.position)
+		s.popTail(pos, replaced)
+		s.path = s.path[:len(s.path)-1]
+		s.directions = s.directions[:len(s.directions)-1]
+		s.joins = s.joins[:len(s.joins)-1]
+		s.splits -= splits
+		s.used[index] = false
+	}
+}
+
+// pushTail updates the tails for a way added to the path, and returns what is needed to undo it with popTail
+func (s *pathSolver) pushTail(index int) (int, int) {
+	pos, _ := slices.BinarySearch(s.tails, index)
+	if pos == len(s.tails) {
+		s.tails = append(s.tails, index)
+		return pos, -1
+	}
+	replaced := s.tails[pos]
+	s.tails[pos] = index
+	return pos, replaced
+}
+
+func (s *pathSolver) popTail(pos int, replaced int) {
+	if replaced < 0 {
+		s.tails = s.tails[:pos]
+		return
+	}
+	s.tails[pos] = replaced
+}
+
+func (s *pathSolver) extend(position []int64) {
+	remaining := len(s.ways) - len(s.path)
+	kept := len(s.tails)
+	if kept+remaining < s.bestKept || (kept+remaining == s.bestKept && s.splits >= s.bestSplits) {
+		//Can't do better than the best path already found
+		return
+	}
+	if remaining == 0 {
+		s.best = slices.Clone(s.path)
+		s.bestDirections = slices.Clone(s.directions)
+		s.bestJoins = slices.Clone(s.joins)
+		s.bestKept = kept
+		s.bestSplits = s.splits
+		return
+	}
+
+	for _, nid := range position {
+		for _, i := range s.nodeWays[nid] {
+			if !s.used[i] {
+				s.visit(i, nid)
+			}
+		}
+	}
+}
+
+type traversal struct {
+	direction wayTraversal
+	//position is the nodes where the path can leave the way, ends first so that paths without splits are found first
+	position []int64
+}
+
+// getTraversals returns the ways a way can be traversed from the node the path joins it at (0 at the start of the
+// path), and the nodes the path can leave it at
+func getTraversals(way osm.Way, join int64) []traversal {
+	if way.IsCircular() {
+		return []traversal{{direction: traverseAny, position: way.Nodes[:len(way.Nodes)-1]}}
+	}
+	nodes := way.Nodes
+	last := len(nodes) - 1
+	forwardFrom := slices.Index(nodes, join)
+	reverseFrom := forwardFrom
+	if join == 0 {
+		forwardFrom = 0
+		reverseFrom = last
+	}
+
+	traversals := []traversal{}
+	if forwardFrom >= 0 && forwardFrom < last {
+		position := append([]int64{nodes[last]}, nodes[forwardFrom+1:last]...)
+		traversals = append(traversals, traversal{direction: traverseForward, position: position})
+	}
+	if reverseFrom > 0 {
+		position := append([]int64{nodes[0]}, nodes[1:reverseFrom]...)
+		traversals = append(traversals, traversal{direction: traverseReverse, position: position})
+	}
+	return traversals
+}
+
+// getStartCandidates returns the indexes of the ways a path could start with. Ways with an end that no other way
+// joins are tried first, as a route which isn't a loop has to start at one
+func getStartCandidates(ways []osm.Way) []int {
+	joins := map[int64]int{}
+	for _, way := range ways {
+		for _, nid := range getJoiningNodes(way) {
+			joins[nid]++
+		}
+	}
+
+	isTerminal := func(way osm.Way) bool {
+		return !way.IsCircular() && (joins[way.GetFirstNode()] == 1 || joins[way.GetLastNode()] == 1)
+	}
+
+	candidates := []int{}
+	for i := range ways {
+		candidates = append(candidates, i)
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return isTerminal(ways[candidates[a]]) && !isTerminal(ways[candidates[b]])
+	})
+	return candidates
+}
+
+// longestIncreasing marks the longest run of ways in a path, not necessarily adjacent, which keep their original order
+func longestIncreasing(path []int) []bool {
+	tails := []int{}
+	previous := make([]int, len(path))
+	for i, index := range path {
+		pos := sort.Search(len(tails), func(j int) bool { return path[tails[j]] >= index })
+		previous[i] = -1
+		if pos > 0 {
+			previous[i] = tails[pos-1]
+		}
+		if pos == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[pos] = i
+		}
+	}
+
+	kept := make([]bool, len(path))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			kept[i] = true
+		}
+	}
+	return kept
+}
@@ -0,0 +1,179 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_SolveWayOrder(t *testing.T) {
+
+	testcases := []struct {
+		name    string
+		members []osm.Member
+		checkFn func(t *testing.T, order *WayOrder)
+	}{
+		{
+			name:    "should keep a valid order",
+			members: setupWays(1, 2, 3),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				require.NotNil(t, order)
+				assert.Equal(t, setupWays(1, 2, 3), order.Ways)
+				assert.Empty(t, order.OutOfPlace)
+				assert.True(t, order.Minimal)
+			},
+		},
+		{
+			name:    "should move a single way",
+			members: setupWays(1, 3, 2),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				require.NotNil(t, order)
+				assert.Equal(t, setupWays(1, 2, 3), order.Ways)
+				assert.Equal(t, setupWays(2), order.OutOfPlace)
+			},
+		},
+		{
+			name:    "should move a way at the start of the route",
+			members: setupWays(3, 1, 2),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				require.NotNil(t, order)
+				assert.Equal(t, setupWays(3, 2, 1), order.Ways)
+				assert.Equal(t, setupWays(2), order.OutOfPlace)
+			},
+		},
+		{
+			name:    "should respect oneway tags",
+			members: setupWays(6, 5),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				require.NotNil(t, order)
+				assert.Equal(t, setupWays(5, 6), order.Ways)
+				assert.Len(t, order.OutOfPlace, 1)
+			},
+		},
+		{
+			name:    "should pass through a circular way",
+			members: setupWays(5, 4, 3),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				require.NotNil(t, order)
+				assert.Empty(t, order.OutOfPlace)
+			},
+		},
+		{
+			name:    "should join a way part-way along another",
+			members: setupWays(1, 12, 2, 3),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				require.NotNil(t, order)
+				assert.Equal(t, setupWays(1, 2, 3, 12), order.Ways)
+				assert.Equal(t, setupWays(12), order.OutOfPlace)
+				assert.Equal(t, []int64{100, 101, 101, 102, 102, 103, 103, 117}, getAllNodesInOrder(order.wayDirects))
+			},
+		},
+		{
+			name:    "should return nil if the ways are not connected",
+			members: setupWays(1, 11),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				assert.Nil(t, order)
+			},
+		},
+		{
+			name:    "should return nil if a way is missing",
+			members: setupWays(1, 99),
+			checkFn: func(t *testing.T, order *WayOrder) {
+				assert.Nil(t, order)
+			},
+		},
+	}
+
+	store, err := loadTestStore()
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			validator := NewValidator(DefaultConfig(), store)
+			order, err := validator.SolveWayOrder(context.Background(), osm.Relation{Members: tc.members})
+			require.NoError(t, err)
+			tc.checkFn(t, order)
+		})
+	}
+}
+
+func TestValidator_SolveWayOrder_cancelled(t *testing.T) {
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	order, err := validator.SolveWayOrder(ctx, osm.Relation{Members: setupWays(3, 1, 2)})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, order)
+}
+
+func TestWayOrder_Members(t *testing.T) {
+	stop := osm.Member{Type: "node", Ref: 101, Role: osm.RoleStop}
+	platform := osm.Member{Type: "way", Ref: 200, Role: osm.RolePlatform}
+	relation := osm.Relation{Members: append([]osm.Member{stop, platform}, setupWays(1, 3, 2)...)}
+
+	order := &WayOrder{Ways: setupWays(1, 2, 3)}
+	assert.Equal(t, append([]osm.Member{stop, platform}, setupWays(1, 2, 3)...), order.Members(relation))
+}
+
+func Test_validateWayOrder_outOfPlace(t *testing.T) {
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
+
+	validationErrors, _, _, err := validator.validateWayOrder(context.Background(), osm.Relation{Members: setupWays(3, 1, 2)})
+	require.NoError(t, err)
+	assertContainsValidationError(t, validationErrors, ValidationError{
+		URL:     "https://www.openstreetmap.org/way/2",
+		Message: "way is out of place, it should come after way 3",
+	})
+}
+
+func Test_validateWayOrder_outOfPlaceCutOff(t *testing.T) {
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
+
+	//The ways which are out of place aren't reported if the search doesn't finish
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	validationErrors, _, _, err := validator.validateWayOrder(ctx, osm.Relation{Members: setupWays(3, 1, 2)})
+	require.NoError(t, err)
+	for _, ve := range validationErrors {
+		assert.NotContains(t, ve.Message, "out of place")
+	}
+}
+
+func TestValidator_SortMembers(t *testing.T) {
+	store, err := loadTestStore()
+	require.NoError(t, err)
+	validator := NewValidator(DefaultConfig(), store)
+
+	stop100 := osm.Member{Type: "node", Ref: 100, Role: osm.RoleStop}
+	stop102 := osm.Member{Type: "node", Ref: 102, Role: osm.RoleStop}
+	platform := osm.Member{Type: "way", Ref: 200, Role: osm.RolePlatform}
+
+	relation := osm.Relation{Members: append([]osm.Member{stop102, platform, stop100}, setupWays(1, 3, 2)...)}
+	members, err := validator.SortMembers(context.Background(), relation)
+	require.NoError(t, err)
+	assert.Equal(t, append([]osm.Member{stop100, stop102, platform}, setupWays(1, 2, 3)...), members)
+
+	//Platforms without stops are placed where they are nearest the route
+	platform131 := osm.Member{Type: "node", Ref: 131, Role: osm.RolePlatform}
+	platform132 := osm.Member{Type: "node", Ref: 132, Role: osm.RolePlatform}
+	platform19 := osm.Member{Type: "way", Ref: 19, Role: osm.RolePlatform}
+	relation = osm.Relation{Members: append([]osm.Member{platform132, platform19, platform131}, setupWays(1, 3, 2)...)}
+	members, err = validator.SortMembers(context.Background(), relation)
+	require.NoError(t, err)
+	assert.Equal(t, append([]osm.Member{platform131, platform19, platform132}, setupWays(1, 2, 3)...), members)
+
+	relation = osm.Relation{Members: append([]osm.Member{stop100}, setupWays(1, 11)...)}
+	members, err = validator.SortMembers(context.Background(), relation)
+	require.NoError(t, err)
+	assert.Nil(t, members)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
+	"github.com/ockendenjo/osm-pt-validator/pkg/replication"
+	"github.com/ockendenjo/osm-pt-validator/pkg/routes"
+)
+
+// Prints the routes affected by local osmChange files, e.g. replication diffs downloaded from
+// https://planet.openstreetmap.org/replication/minute
+func main() {
+	ctx := context.Background()
+
+	routesFiles := []string{}
+	flag.Func("f", "Routes file to watch (can be repeated)", func(s string) error {
+		routesFiles = append(routesFiles, s)
+		return nil
+	})
+	var dataFile string
+	flag.StringVar(&dataFile, "data", "", "OSM XML or PBF file to load routes from instead of the OSM API")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -f <routesFile> [-data <extract>] <diff.osc[.gz]>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(routesFiles) < 1 || flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files := []routes.RoutesFile{}
+	for _, path := range routesFiles {
+		file, err := readRoutesFile(path)
+		if err != nil {
+			panic(err)
+		}
+		files = append(files, file)
+	}
+
+	provider, err := newProvider(dataFile)
+	if err != nil {
+		panic(err)
+	}
+	index, err := replication.BuildIndex(ctx, provider, files)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("watching %d relations", len(index.Routes))
+	for relationId, loadErr := range index.Failed {
+		log.Printf("failed to load relation %d: %s", relationId, loadErr)
+	}
+
+	for _, path := range flag.Args() {
+		change, err := replication.LoadDiffFile(path)
+		if err != nil {
+			panic(err)
+		}
+		checkEvents := index.Match(change)
+		log.Printf("%s: %d relations changed", path, len(checkEvents))
+		for _, event := range checkEvents {
+			log.Printf("https://www.openstreetmap.org/relation/%d", event.RelationID)
+		}
+		index.Update(change)
+	}
+}
+
+func readRoutesFile(path string) (routes.RoutesFile, error) {
+	bytes, err := os.ReadFile(path) // #nosec G304 -- File inclusion via variable is intentional
+	if err != nil {
+		return routes.RoutesFile{}, err
+	}
+	var file routes.RoutesFile
+	err = json.Unmarshal(bytes, &file)
+	return file, err
+}
+
+func newProvider(dataFile string) (osm.Provider, error) {
+	if dataFile != "" {
+		return osm.LoadFile(dataFile)
+	}
+	return osm.NewClient("osm-pt-validator https://github.com/ockendenjo/osm-pt-validator").WithRateLimit(osm.DefaultRateLimit()).WithLogger(slog.Default()), nil
+}
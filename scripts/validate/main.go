@@ -8,12 +8,17 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ockendenjo/osm-pt-validator/pkg/osm"
 	"github.com/ockendenjo/osm-pt-validator/pkg/routes"
+	"github.com/ockendenjo/osm-pt-validator/pkg/state"
 	"github.com/ockendenjo/osm-pt-validator/pkg/validation"
 )
 
@@ -26,17 +31,160 @@ func main() {
 	flag.BoolVar(&npt, "npt", false, "Verify NaPTAN platform tags")
 	var inputFile string
 	flag.StringVar(&inputFile, "f", "", "Routes file (validation config read from file too)")
+	rateLimit := osm.DefaultRateLimit()
+	flag.Float64Var(&rateLimit.RequestsPerSecond, "rps", rateLimit.RequestsPerSecond, "Maximum OSM API requests per second")
+	flag.IntVar(&rateLimit.MaxConcurrent, "conns", rateLimit.MaxConcurrent, "Maximum concurrent OSM API connections")
+	var overpassUrl string
+	flag.StringVar(&overpassUrl, "overpass", "", "Overpass API interpreter URL to load data from instead of the OSM API, e.g. "+osm.DefaultOverpassUrl)
+	var xml bool
+	flag.BoolVar(&xml, "xml", false, "Request OSM XML instead of JSON, for OSM API or Overpass mirrors which only support XML")
+	var dataFile string
+	flag.StringVar(&dataFile, "data", "", "OSM XML or PBF file to validate against instead of loading data from the network")
+	var recordFile string
+	flag.StringVar(&recordFile, "record", "", "Save every OSM response to a cassette file, so the run can be replayed later")
+	var replayFile string
+	flag.StringVar(&replayFile, "replay", "", "Replay OSM responses from a cassette file saved with -record")
+	var at string
+	flag.StringVar(&at, "at", "", "Validate relations as they were at a time (e.g. 2026-09-01T00:00:00Z) or after a changeset ID")
+	var stateDir string
+	flag.StringVar(&stateDir, "state", "", "Directory to save results in, so relations which have not changed are not validated again")
+	var fixFile string
+	flag.StringVar(&fixFile, "fix", "", "Write routes with their members sorted to an osmChange (.osc) or JOSM (.osm) file")
+	var changesetId int64
+	flag.Int64Var(&changesetId, "changeset", 0, "Open changeset to upload the -fix osmChange file in. Without it, the file must be opened in JOSM to upload it")
 	flag.Parse()
 
 	if relationId < 1 && inputFile == "" {
 		panic(errors.New("relationID (-r) or routes file (-f) must be specified"))
 	}
+	if changesetId > 0 && !strings.HasSuffix(fixFile, ".osc") {
+		panic(errors.New("-changeset can only be used with an osmChange (.osc) -fix file"))
+	}
+
+	cassette := osm.NewCassette()
+	ctx = osm.ContextWithCassette(ctx, cassette)
+	options := providerOptions{
+		overpassUrl: overpassUrl,
+		xml:         xml,
+		dataFile:    dataFile,
+		record:      recordFile != "",
+		replayFile:  replayFile,
+		at:          at,
+	}
+	provider, err := newProvider(rateLimit, options)
+	if err != nil {
+		panic(err)
+	}
+	var stateStore state.Store
+	if stateDir != "" {
+		stateStore, err = state.NewFileStore(stateDir)
+		if err != nil {
+			panic(err)
+		}
+	}
 
+	var fixes *osm.Elements
+	if fixFile != "" {
+		fixes = &osm.Elements{Relations: map[int64]osm.Relation{}}
+	}
+
+	var isValid bool
 	if relationId > 0 {
-		validateSingleRelation(ctx, relationId, npt)
-		return
+		isValid = validateSingleRelation(ctx, provider, stateStore, fixes, relationId, npt)
+	} else {
+		isValid = validateFile(ctx, provider, stateStore, fixes, inputFile)
+	}
+
+	if fixes != nil {
+		err = writeFixes(fixFile, *fixes, changesetId)
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("saved %d sorted routes to %s", len(fixes.Relations), fixFile)
+	}
+
+	if recordFile != "" {
+		err = cassette.Save(recordFile)
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("saved %d responses to %s", cassette.Len(), recordFile)
+	}
+	if !isValid {
+		os.Exit(1)
 	}
-	validateFile(ctx, inputFile)
+}
+
+type providerOptions struct {
+	overpassUrl string
+	xml         bool
+	dataFile    string
+	record      bool
+	replayFile  string
+	at          string
+}
+
+func newProvider(rateLimit osm.RateLimit, options providerOptions) (osm.Provider, error) {
+	if options.dataFile != "" {
+		if options.at != "" {
+			return nil, errors.New("-at cannot be used with -data")
+		}
+		if options.record || options.replayFile != "" {
+			return nil, errors.New("-record and -replay cannot be used with -data")
+		}
+		if options.xml {
+			return nil, errors.New("-xml cannot be used with -data")
+		}
+		return osm.LoadFile(options.dataFile)
+	}
+
+	osmClient := newOSMClient(rateLimit)
+	if options.xml {
+		osmClient.WithXML()
+	}
+	if options.overpassUrl != "" {
+		osmClient.WithOverpass(options.overpassUrl)
+	}
+	if options.record {
+		osmClient.WithRecording()
+	}
+	if options.replayFile != "" {
+		cassette, err := osm.LoadCassette(options.replayFile)
+		if err != nil {
+			return nil, err
+		}
+		osmClient.WithReplay(cassette)
+	}
+	if options.at != "" {
+		at, err := parseAsOf(options.at)
+		if err != nil {
+			return nil, err
+		}
+		return osmClient.AsOf(at), nil
+	}
+	return osmClient, nil
+}
+
+// parseAsOf parses either a changeset ID or an RFC 3339 timestamp
+func parseAsOf(value string) (osm.AsOf, error) {
+	changeset, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return osm.AsOf{Changeset: changeset}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return osm.AsOf{}, fmt.Errorf("invalid -at value %q, expected a changeset ID or RFC 3339 timestamp", value)
+	}
+	return osm.AsOf{Time: t}, nil
+}
+
+func newOSMClient(rateLimit osm.RateLimit) *osm.OSMClient {
+	userAgent, err := getUserAgent()
+	if err != nil {
+		panic(err)
+	}
+	rateLimit.Burst = rateLimit.MaxConcurrent
+	return osm.NewClient(userAgent).WithRateLimit(rateLimit).WithLogger(slog.Default())
 }
 
 func getUserAgent() (string, error) {
@@ -51,7 +199,7 @@ func getUserAgent() (string, error) {
 	return userAgent, nil
 }
 
-func validateFile(ctx context.Context, inputFile string) {
+func validateFile(ctx context.Context, provider osm.Provider, stateStore state.Store, fixes *osm.Elements, inputFile string) bool {
 	file, err := os.Open(inputFile) // #nosec G304 -- File inclusion via variable is intentional
 	if err != nil {
 		panic(err)
@@ -65,13 +213,7 @@ func validateFile(ctx context.Context, inputFile string) {
 	if err != nil {
 		panic(err)
 	}
-	userAgent, err := getUserAgent()
-	if err != nil {
-		panic(err)
-	}
-
-	osmClient := osm.NewClient(userAgent)
-	validator := validation.NewValidator(routesFile.Config, osmClient)
+	validator := validation.NewValidator(routesFile.Config, provider)
 
 	allValid := true
 	for _, routeList := range routesFile.Routes {
@@ -83,12 +225,12 @@ func validateFile(ctx context.Context, inputFile string) {
 				continue
 			}
 
-			relation, err := osmClient.GetRelation(ctx, r.RelationID)
+			full, err := provider.GetRelationFull(ctx, r.RelationID)
 			if err != nil {
 				panic(err)
 			}
 
-			isValid, err := doValidation(ctx, validator, osmClient, relation)
+			isValid, err := doValidation(ctx, validator, provider, stateStore, fixes, full)
 			if err != nil {
 				panic(err)
 			}
@@ -98,79 +240,192 @@ func validateFile(ctx context.Context, inputFile string) {
 		}
 	}
 
-	if !allValid {
-		os.Exit(1)
-	}
+	return allValid
 }
 
-func validateSingleRelation(ctx context.Context, relationId int64, npt bool) {
-	userAgent, err := getUserAgent()
-	if err != nil {
-		panic(err)
-	}
-	osmClient := osm.NewClient(userAgent)
-	relation, err := osmClient.GetRelation(ctx, relationId)
+func validateSingleRelation(ctx context.Context, provider osm.Provider, stateStore state.Store, fixes *osm.Elements, relationId int64, npt bool) bool {
+	full, err := provider.GetRelationFull(ctx, relationId)
 	if err != nil {
 		panic(err)
 	}
 
-	validator := validation.NewValidator(validation.Config{NaptanPlatformTags: npt}, osmClient)
+	validator := validation.NewValidator(validation.Config{NaptanPlatformTags: npt}, provider)
 
-	isValid, err := doValidation(ctx, validator, osmClient, relation)
+	isValid, err := doValidation(ctx, validator, provider, stateStore, fixes, full)
 	if err != nil {
 		panic(err)
 	}
-	if !isValid {
-		os.Exit(1)
-	}
+	return isValid
 }
 
-func doValidation(ctx context.Context, validator *validation.Validator, osmClient *osm.OSMClient, relation osm.Relation) (bool, error) {
+func doValidation(ctx context.Context, validator *validation.Validator, provider osm.Provider, stateStore state.Store, fixes *osm.Elements, full osm.FullRelation) (bool, error) {
 
-	switch relation.Tags["type"] {
+	switch full.Relation.Tags["type"] {
 	case "route":
-		return validateRoute(ctx, validator, relation)
+		return validateRoute(ctx, validator, stateStore, fixes, full)
 	case "route_master":
-		return validateRouteMaster(ctx, validator, osmClient, relation)
+		return validateRouteMaster(ctx, validator, provider, stateStore, fixes, full.Relation)
 	default:
 		return false, errors.New("unknown relation type")
 	}
 }
 
-func validateRouteMaster(ctx context.Context, validator *validation.Validator, osmClient *osm.OSMClient, relation osm.Relation) (bool, error) {
+func validateRouteMaster(ctx context.Context, validator *validation.Validator, provider osm.Provider, stateStore state.Store, fixes *osm.Elements, relation osm.Relation) (bool, error) {
 	log.Printf("validating relation: %s", relation.GetElementURL())
 
-	validationErrors := validator.RouteMaster(relation)
-	printErrors(validationErrors)
-	isValid := len(validationErrors) < 1
-
+	subRelations := []osm.FullRelation{}
+	variants := []osm.Relation{}
 	for _, member := range relation.Members {
 		if member.Type == "relation" {
-			fmt.Println("")
-			subRelation, err := osmClient.GetRelation(ctx, member.Ref)
-			if err != nil {
-				return false, err
-			}
-			subIsValid, err := validateRoute(ctx, validator, subRelation)
-			isValid = isValid && subIsValid
+			subRelation, err := provider.GetRelationFull(ctx, member.Ref)
 			if err != nil {
 				return false, err
 			}
+			subRelations = append(subRelations, subRelation)
+			variants = append(variants, subRelation.Relation)
+		}
+	}
+
+	//Route variants are validated separately, so the fingerprint only covers the route master and the variants' own tags
+	full := osm.FullRelation{Relation: relation, Relations: map[int64]osm.Relation{}}
+	for _, variant := range variants {
+		full.Relations[variant.ID] = variant
+	}
+	validationErrors, err := validateUnlessUnchanged(ctx, stateStore, full, validator.GetConfig(), func() ([]validation.ValidationError, error) {
+		return validator.RouteMaster(relation, variants), nil
+	})
+	if err != nil {
+		return false, err
+	}
+	printErrors(validationErrors)
+	isValid := len(validationErrors) < 1
+
+	for _, subRelation := range subRelations {
+		fmt.Println("")
+		subIsValid, err := validateRoute(ctx, validator, stateStore, fixes, subRelation)
+		isValid = isValid && subIsValid
+		if err != nil {
+			return false, err
 		}
 	}
 
 	return isValid, nil
 }
 
-func validateRoute(ctx context.Context, validator *validation.Validator, relation osm.Relation) (bool, error) {
-	log.Printf("validating relation: %s", relation.GetElementURL())
-	validationErrors, err := validator.RouteRelation(ctx, relation)
+func validateRoute(ctx context.Context, validator *validation.Validator, stateStore state.Store, fixes *osm.Elements, full osm.FullRelation) (bool, error) {
+	log.Printf("validating relation: %s", full.Relation.GetElementURL())
+	err := validator.AddStopAreas(ctx, &full)
+	if err != nil {
+		return false, err
+	}
+	validationErrors, err := validateUnlessUnchanged(ctx, stateStore, full, validator.GetConfig(), func() ([]validation.ValidationError, error) {
+		return validator.RouteRelation(ctx, full.Relation)
+	})
 	if err != nil {
 		return false, err
 	}
 	printErrors(validationErrors)
 	isValid := len(validationErrors) < 1
-	return isValid, nil
+	if !isValid {
+		err = printWayOrder(ctx, validator, full.Relation)
+		if err != nil {
+			return false, err
+		}
+	}
+	if !isValid && fixes != nil {
+		err = addFix(ctx, validator, fixes, full.Relation)
+	}
+	return isValid, err
+}
+
+// addFix adds the relation with its members sorted to the fixes, if sorting changes the order
+func addFix(ctx context.Context, validator *validation.Validator, fixes *osm.Elements, relation osm.Relation) error {
+	members, err := validator.SortMembers(ctx, relation)
+	if err != nil {
+		return err
+	}
+	if members == nil {
+		log.Println("members can't be sorted, as the ways don't form a continuous route")
+		return nil
+	}
+	if slices.Equal(members, relation.Members) {
+		return nil
+	}
+	relation.Members = members
+	fixes.Relations[relation.ID] = relation
+	return nil
+}
+
+// writeFixes writes the sorted relations as an osmChange file in the changeset, or as a JOSM file unless the path ends
+// with .osc
+func writeFixes(path string, fixes osm.Elements, changesetId int64) error {
+	file, err := os.Create(path) // #nosec G304 -- File inclusion via variable is intentional
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(path, ".osc") {
+		err = osm.EncodeOsmChange(file, &osm.OsmChange{Modify: fixes}, changesetId)
+	} else {
+		err = osm.EncodeJOSM(file, fixes)
+	}
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// printWayOrder prints the order the member ways should be in, if they are out of order
+func printWayOrder(ctx context.Context, validator *validation.Validator, relation osm.Relation) error {
+	order, err := validator.SolveWayOrder(ctx, relation)
+	if err != nil || order == nil || len(order.OutOfPlace) < 1 {
+		return err
+	}
+	wayIds := []string{}
+	for _, member := range order.Ways {
+		wayIds = append(wayIds, strconv.FormatInt(member.Ref, 10))
+	}
+	if !order.Minimal {
+		log.Printf("proposed way order (there were too many ways to check it moves the fewest): %s", strings.Join(wayIds, ", "))
+		return nil
+	}
+	log.Printf("proposed way order: %s", strings.Join(wayIds, ", "))
+	return nil
+}
+
+// validateUnlessUnchanged reuses the previous result if the relation, its members and the config have not changed
+// since it was last validated. Otherwise, it validates the relation and saves the result
+func validateUnlessUnchanged(
+	ctx context.Context,
+	stateStore state.Store,
+	full osm.FullRelation,
+	config validation.Config,
+	validate func() ([]validation.ValidationError, error),
+) ([]validation.ValidationError, error) {
+	if stateStore == nil {
+		return validate()
+	}
+
+	fingerprint, err := state.NewFingerprint(full, config)
+	if err != nil {
+		return nil, err
+	}
+	previous, found, err := stateStore.Get(ctx, full.Relation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if found && previous.Fingerprint.Equal(fingerprint) {
+		log.Printf("relation has not changed since %s", previous.ValidatedAt.Format(time.RFC3339))
+		return previous.ValidationErrors, nil
+	}
+
+	validationErrors, err := validate()
+	if err != nil {
+		return nil, err
+	}
+	result := state.Result{Fingerprint: fingerprint, ValidationErrors: validationErrors, ValidatedAt: time.Now().UTC()}
+	return validationErrors, stateStore.Put(ctx, full.Relation.ID, result)
 }
 
 func printErrors(validationErrors []validation.ValidationError) {
@@ -0,0 +1,56 @@
+module "lambda_replication" {
+  source                   = "github.com/ockendenjo/tfmods//lambda"
+  aws_env                  = var.env
+  name                     = "replication"
+  permissions_boundary_arn = var.permissions_boundary_arn
+  project_name             = "osmptv"
+  s3_bucket                = var.lambda_binaries_bucket
+  s3_object_key            = local.manifest["replication"]
+  alarm_topic_arn          = aws_sns_topic.alarms.arn
+  timeout                  = 50
+
+  environment = {
+    S3_BUCKET_NAME  = aws_s3_bucket.data.id
+    QUEUE_URL       = module.sqs_validate_rm_events.queue_url
+    USER_AGENT      = "https://github.com/ockendenjo/osm-pt-validator"
+    OSM_MAX_RPS     = var.osm_max_rps
+    OSM_MAX_CONNS   = var.osm_max_conns
+    OVERPASS_URL    = var.overpass_url
+    REPLICATION_URL = var.replication_url
+  }
+}
+
+module "iam_s3_lambda_replication" {
+  source     = "github.com/ockendenjo/tfmods//iam-s3"
+  bucket_arn = aws_s3_bucket.data.arn
+  role_id    = module.lambda_replication.role_id
+}
+
+module "iam_sqs_lambda_replication" {
+  source  = "github.com/ockendenjo/tfmods//iam-sqs"
+  role_id = module.lambda_replication.role_id
+  sqs_arns = [
+    module.sqs_validate_rm_events.queue_arn,
+  ]
+}
+
+resource "aws_cloudwatch_event_rule" "replication_schedule" {
+  name                = "osmptv-${var.env}-replication-schedule"
+  description         = "Check OSM replication diffs for edits to watched routes"
+  schedule_expression = var.replication_schedule
+  state               = var.replication_enabled ? "ENABLED" : "DISABLED"
+}
+
+resource "aws_cloudwatch_event_target" "replication_lambda" {
+  rule      = aws_cloudwatch_event_rule.replication_schedule.name
+  target_id = "ReplicationLambda"
+  arn       = module.lambda_replication.arn
+}
+
+resource "aws_lambda_permission" "allow_eventbridge_replication" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = module.lambda_replication.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.replication_schedule.arn
+}
@@ -7,14 +7,28 @@ module "lambda_split_relation" {
   s3_bucket                = var.lambda_binaries_bucket
   s3_object_key            = local.manifest["validate-rm"]
   alarm_topic_arn          = aws_sns_topic.alarms.arn
+  # Each variant of a route master is loaded with a separate request, so the timeout must allow for the route master with
+  # the most variants at osm_max_rps. It must not be longer than the visibility timeout of the validate-rm-events queue
+  timeout = 30
 
   environment = {
-    QUEUE_URL  = module.sqs_validate_route_events.queue_url
-    TOPIC_ARN  = aws_sns_topic.invalid_relations.arn
-    USER_AGENT = "https://github.com/ockendenjo/osm-pt-validator"
+    QUEUE_URL            = module.sqs_validate_route_events.queue_url
+    TOPIC_ARN            = aws_sns_topic.invalid_relations.arn
+    USER_AGENT           = "https://github.com/ockendenjo/osm-pt-validator"
+    OSM_MAX_RPS          = var.osm_max_rps
+    OSM_MAX_CONNS        = var.osm_max_conns
+    OVERPASS_URL         = var.overpass_url
+    CASSETTE_BUCKET_NAME = var.record_cassettes ? aws_s3_bucket.data.id : ""
+    STATE_BUCKET_NAME    = var.skip_unchanged ? aws_s3_bucket.data.id : ""
   }
 }
 
+module "iam_s3_lambda_split_relation" {
+  source     = "github.com/ockendenjo/tfmods//iam-s3"
+  bucket_arn = aws_s3_bucket.data.arn
+  role_id    = module.lambda_split_relation.role_id
+}
+
 module "iam_sns_lambda_split_relation" {
   source  = "github.com/ockendenjo/tfmods//iam-sns"
   role_id = module.lambda_split_relation.role_id
@@ -7,14 +7,27 @@ module "lambda_validate_route" {
   s3_bucket                = var.lambda_binaries_bucket
   s3_object_key            = local.manifest["validate-route"]
   alarm_topic_arn          = aws_sns_topic.alarms.arn
-  timeout                  = 20
+  # Routes which check stop areas need a request for each stop and platform, as well as the time allowed for blame. The
+  # timeout must not be longer than the visibility timeout of the validate-route-events queue
+  timeout = 30
 
   environment = {
-    TOPIC_ARN  = aws_sns_topic.invalid_relations.arn
-    USER_AGENT = "https://github.com/ockendenjo/osm-pt-validator"
+    TOPIC_ARN            = aws_sns_topic.invalid_relations.arn
+    USER_AGENT           = "https://github.com/ockendenjo/osm-pt-validator"
+    OSM_MAX_RPS          = var.osm_max_rps
+    OSM_MAX_CONNS        = var.osm_max_conns
+    OVERPASS_URL         = var.overpass_url
+    CASSETTE_BUCKET_NAME = var.record_cassettes ? aws_s3_bucket.data.id : ""
+    STATE_BUCKET_NAME    = var.skip_unchanged ? aws_s3_bucket.data.id : ""
   }
 }
 
+module "iam_s3_lambda_validate_route" {
+  source     = "github.com/ockendenjo/tfmods//iam-s3"
+  bucket_arn = aws_s3_bucket.data.arn
+  role_id    = module.lambda_validate_route.role_id
+}
+
 module "iam_sns_lambda_validate_route" {
   source  = "github.com/ockendenjo/tfmods//iam-sns"
   role_id = module.lambda_validate_route.role_id
@@ -2,3 +2,20 @@ resource "aws_s3_bucket" "data" {
   bucket_prefix = "osmptv-${var.env}-data-"
   force_destroy = true
 }
+
+resource "aws_s3_bucket_lifecycle_configuration" "data" {
+  bucket = aws_s3_bucket.data.id
+
+  rule {
+    id     = "expire-cassettes"
+    status = "Enabled"
+
+    filter {
+      prefix = "cassettes/"
+    }
+
+    expiration {
+      days = 30
+    }
+  }
+}
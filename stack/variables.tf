@@ -15,3 +15,51 @@ variable "permissions_boundary_arn" {
 variable "lambda_binaries_bucket" {
   type = string
 }
+
+variable "osm_max_rps" {
+  description = "Maximum requests per second each Lambda instance makes to the OSM API"
+  type        = number
+  default     = 5
+}
+
+variable "osm_max_conns" {
+  description = "Maximum concurrent connections each Lambda instance makes to the OSM API"
+  type        = number
+  default     = 2
+}
+
+variable "overpass_url" {
+  description = "Overpass API interpreter URL to load OSM data from. The OSM API is used if empty"
+  type        = string
+  default     = ""
+}
+
+variable "record_cassettes" {
+  description = "Save the OSM responses used to validate invalid relations to the data bucket, so they can be replayed"
+  type        = bool
+  default     = true
+}
+
+variable "skip_unchanged" {
+  description = "Reuse the previous result for relations which have not changed since they were last validated"
+  type        = bool
+  default     = true
+}
+
+variable "replication_enabled" {
+  description = "Validate routes as soon as they are edited, using OSM replication diffs, as well as the daily schedule"
+  type        = bool
+  default     = false
+}
+
+variable "replication_url" {
+  description = "OSM replication URL to load diffs from, e.g. https://planet.openstreetmap.org/replication/hour"
+  type        = string
+  default     = "https://planet.openstreetmap.org/replication/minute"
+}
+
+variable "replication_schedule" {
+  description = "How often to check for new replication diffs, which should match the replication URL"
+  type        = string
+  default     = "rate(1 minute)"
+}